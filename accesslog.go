@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// AccessLogConfig configures the access log file and when it is rotated.
+type AccessLogConfig struct {
+	File        string `json:"file"`
+	MaxSizeMB   int    `json:"max_size_mb"`
+	RotateHours int    `json:"rotate_hours"`
+}
+
+// rotatingFile is an append-only log file that is renamed aside once it
+// exceeds maxSize bytes or has been open longer than interval. Zero limits
+// disable the respective check.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	interval time.Duration
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// openRotatingFile opens path for appending with the given rotation limits.
+func openRotatingFile(path string, maxSize int64, interval time.Duration) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxSize: maxSize, interval: interval}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open (re)opens the current log file, picking up its existing size.
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open access log: %v", err)
+	}
+	rf.f, rf.size, rf.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// rotate renames the current file to path.<timestamp> and starts a new one.
+func (rf *rotatingFile) rotate() error {
+	rf.f.Close()
+	stamp := rf.path + "." + time.Now().Format("20060102-150405")
+	rotated := stamp
+	// Never overwrite an earlier rotation from the same second
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s.%d", stamp, i)
+	}
+	if err := os.Rename(rf.path, rotated); err != nil {
+		// Keep logging to the old file rather than losing lines
+		log.Printf("Failed to rotate access log: %v", err)
+	}
+	return rf.open()
+}
+
+// Write appends p, rotating first if it would push the file over a limit.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	tooBig := rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize
+	tooOld := rf.interval > 0 && time.Since(rf.opened) >= rf.interval
+	if tooBig || tooOld {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
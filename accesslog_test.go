@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogRotation(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {"wasm_file": "{echo}"}}}`)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	// Each line is well over 100 bytes, so every request after the first rotates
+	rf, err := openRotatingFile(path, 200, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+	h := withRequestID(withAccessLog(rf, s))
+
+	targets := []string{"/echo?out=a", "/missing", "/echo?out=b"}
+	for _, target := range targets {
+		serve(h, "GET", target, "User-Agent", "wasio-test")
+	}
+
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "\n"); n != 1 {
+			t.Errorf("%s holds %d lines, want 1: %q", file, n, data)
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(string(data)), "\n")...)
+	}
+	if len(files) != len(targets) {
+		t.Errorf("got %d files %v, want one per request", len(files), files)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"GET /echo?out=b HTTP/1.1" 200 1 "-" "wasio-test"`; !strings.Contains(string(current), want) {
+		t.Errorf("current log %q lacks %q", current, want)
+	}
+	all := strings.Join(lines, "\n")
+	for _, want := range []string{`"GET /echo?out=a HTTP/1.1" 200 1`, `"GET /missing HTTP/1.1" 404`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("logs lack %q:\n%s", want, all)
+		}
+	}
+}
+
+func TestAccessLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("old line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := openRotatingFile(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rf.Write([]byte("new line\n"))
+	rf.Close()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "old line\nnew line\n" {
+		t.Errorf("log = %q, want the new line appended", data)
+	}
+}
@@ -1,5 +1,6 @@
 cd instruments
 for file in *.go; do
+    case "$file" in *_test.go) continue;; esac
     wasm_file="${file%.go}.wasm"
     tinygo build -o "$wasm_file" -target wasi "$file"
 done
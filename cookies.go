@@ -0,0 +1,16 @@
+package main
+
+import "net/http"
+
+// requestCookies returns the request's cookies by name. If a name appears
+// more than once, the first value wins, as browsers send the most specific
+// cookie first.
+func requestCookies(r *http.Request) map[string]string {
+	cookies := map[string]string{}
+	for _, c := range r.Cookies() {
+		if _, ok := cookies[c.Name]; !ok {
+			cookies[c.Name] = c.Value
+		}
+	}
+	return cookies
+}
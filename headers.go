@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// instrumentOutput is an instrument's output split into the headers it set
+// and the response body.
+type instrumentOutput struct {
+	header  http.Header
+	cookies []*http.Cookie
+	body    []byte
+}
+
+// headerName reports whether name is a well-formed header field name.
+func headerName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedHeaders are X-* headers the server itself sets, by canonical
+// name. An instrument overriding them could weaken the security headers or
+// break request tracing.
+var reservedHeaders = map[string]bool{
+	"X-Content-Type-Options": true,
+	"X-Frame-Options":        true,
+	"X-Request-Id":           true,
+	"X-Xss-Protection":       true,
+}
+
+// allowedHeader reports whether an instrument may set the canonical header
+// name. X-WASIO-* headers and reservedHeaders belong to the server.
+func allowedHeader(name string) bool {
+	switch name {
+	case "Content-Type", "Location", "Set-Cookie":
+		return true
+	}
+	return strings.HasPrefix(name, "X-") && !strings.HasPrefix(name, "X-Wasio-") &&
+		!strings.HasPrefix(name, "X-Forwarded-") && !reservedHeaders[name]
+}
+
+// scanHeaderBlock looks for a header block at the start of output and
+// returns its lines and the body that follows. ok is false if output doesn't
+// start with a complete block; more is then true if the block could still be
+// completed by further output.
+func scanHeaderBlock(output []byte) (lines [][]byte, body []byte, ok, more bool) {
+	rest := output
+	for {
+		line, next, found := bytes.Cut(rest, []byte("\n"))
+		if !found {
+			// The block isn't closed, at least not yet
+			return nil, output, false, true
+		}
+		line, rest = bytes.TrimSuffix(line, []byte("\r")), next
+		if len(line) == 0 {
+			return lines, rest, len(lines) > 0, false
+		}
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if !ok || !headerName(string(name)) {
+			return nil, output, false, false
+		}
+		lines = append(lines, line)
+	}
+}
+
+// splitOutput separates the header block that output may start with from the
+// body, for routes with "headers": true. Like a CGI response, the block is
+// a run of "Name: value" lines, each ended by LF or CRLF, closed by an empty
+// line. Output that doesn't start with such a block is all body. Headers
+// other than Content-Type, Location, Set-Cookie and X-*, and cookies that
+// don't parse or validate, are dropped and reported in err.
+func (route Route) splitOutput(output []byte) (instrumentOutput, error) {
+	out := instrumentOutput{header: http.Header{}, body: output}
+	if !route.Headers {
+		return out, nil
+	}
+
+	lines, rest, ok, _ := scanHeaderBlock(output)
+	if !ok {
+		return out, nil
+	}
+
+	var dropped []string
+	for _, line := range lines {
+		name, value, _ := bytes.Cut(line, []byte(":"))
+		key := textproto.CanonicalMIMEHeaderKey(string(name))
+		v := strings.TrimSpace(string(value))
+		switch {
+		case !allowedHeader(key):
+			dropped = append(dropped, fmt.Sprintf("header %s is not allowed", key))
+		case key == "Set-Cookie":
+			c, err := http.ParseSetCookie(v)
+			if err == nil {
+				err = c.Valid()
+			}
+			if err != nil {
+				dropped = append(dropped, fmt.Sprintf("invalid cookie %q: %v", v, err))
+				continue
+			}
+			out.cookies = append(out.cookies, c)
+		default:
+			out.header.Add(key, v)
+		}
+	}
+	out.body = rest
+	if len(dropped) > 0 {
+		return out, errors.New(strings.Join(dropped, "; "))
+	}
+	return out, nil
+}
+
+// write sends the output to the client. A Location header turns it into a
+// 302 redirect without a body.
+func (out instrumentOutput) write(w http.ResponseWriter) {
+	for key, values := range out.header {
+		w.Header()[key] = values
+	}
+	for _, c := range out.cookies {
+		http.SetCookie(w, c)
+	}
+	if out.header.Get("Location") != "" {
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+	// Output is fully buffered, so the length is known and chunking is unnecessary
+	w.Header().Set("Content-Length", strconv.Itoa(len(out.body)))
+	w.Write(out.body)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// withOutput returns a request target that makes the echo instrument print out.
+func withOutput(path, out string) string {
+	return path + "?out=" + url.QueryEscape(out)
+}
+
+func TestHeaderBlockRedirect(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/headers": {"wasm_file": "{echo}", "headers": true},
+		"/stream": {"wasm_file": "{echo}", "headers": true, "stream": true},
+		"/plain": {"wasm_file": "{echo}"}
+	}}`)
+
+	tests := []struct {
+		name, target string
+		want         int
+		location     string
+		body         string
+	}{
+		{"redirect", withOutput("/headers", "Location: /wiki?page=home\n\nignored"), http.StatusFound, "/wiki?page=home", ""},
+		{"redirect CRLF", withOutput("/headers", "Location: /home\r\n\r\n"), http.StatusFound, "/home", ""},
+		{"streamed redirect", withOutput("/stream", "Location: /home\n\nignored"), http.StatusFound, "/home", ""},
+		{"normal output", withOutput("/headers", "<p>Location: /home</p>\n"), http.StatusOK, "", "<p>Location: /home</p>\n"},
+		{"unclosed block", withOutput("/headers", "Location: /home\n"), http.StatusOK, "", "Location: /home\n"},
+		{"not enabled", withOutput("/plain", "Location: /home\n\n"), http.StatusOK, "", "Location: /home\n\n"},
+	}
+	for _, tt := range tests {
+		w := serve(s, "GET", tt.target)
+		if w.Code != tt.want || w.Header().Get("Location") != tt.location || w.Body.String() != tt.body {
+			t.Errorf("%s: got %d Location %q body %q, want %d Location %q body %q", tt.name,
+				w.Code, w.Header().Get("Location"), w.Body, tt.want, tt.location, tt.body)
+		}
+	}
+}
+
+func TestCookieRoundTrip(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/session": {"wasm_file": "{echo}", "headers": true, "cookies": true}}}`)
+
+	w := serve(s, "GET", withOutput("/session", "Set-Cookie: theme=dark; Path=/; Max-Age=86400; HttpOnly\n"+
+		"Set-Cookie: bad name=x\n\nok"))
+	if w.Body.String() != "ok" {
+		t.Errorf("body = %q, want the output after the header block", w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "theme" || cookies[0].Value != "dark" || !cookies[0].HttpOnly || cookies[0].MaxAge != 86400 {
+		t.Fatalf("cookies = %v, want only the valid theme cookie", cookies)
+	}
+
+	r := httptest.NewRequest("GET", "/session", nil)
+	r.AddCookie(cookies[0])
+	r.Header.Add("Cookie", "theme=light")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if got := decodeEcho(t, w).Payload.Cookies; len(got) != 1 || got["theme"] != "dark" {
+		t.Errorf("payload cookies = %v, want theme=dark", got)
+	}
+}
+
+func TestHeaderBlock(t *testing.T) {
+	s := newTestServer(t, `{"cache_ttl": 300, "routes": {"/headers": {"wasm_file": "{echo}", "headers": true, "cache": true}}}`)
+	target := withOutput("/headers", "Content-Type: text/csv\nX-Total: 2\nX-WASIO-Seed: 1\nServer: evil\n\na,b\n")
+
+	for i := 0; i < 2; i++ {
+		// The second response comes from the cache and must carry the same headers
+		w := serve(s, "GET", target)
+		if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+			t.Errorf("request %d: Content-Type = %q", i, ct)
+		}
+		if got := w.Header().Get("X-Total"); got != "2" {
+			t.Errorf("request %d: X-Total = %q", i, got)
+		}
+		if got := w.Header().Get("Server"); got != "" {
+			t.Errorf("request %d: disallowed Server header %q was sent", i, got)
+		}
+		if seeds := w.Header().Values("X-WASIO-Seed"); len(seeds) > 1 || len(seeds) == 1 && seeds[0] == "1" {
+			t.Errorf("request %d: instrument set X-WASIO-Seed: %v", i, seeds)
+		}
+		if w.Body.String() != "a,b\n" {
+			t.Errorf("request %d: body = %q", i, w.Body)
+		}
+	}
+
+	// Cookie responses belong to one client and are never cached
+	cookie := withOutput("/headers", "Set-Cookie: id=1\n\n")
+	serve(s, "GET", cookie)
+	if w := serve(s, "GET", cookie); w.Header().Get("X-WASIO-Seed") == "" {
+		t.Error("response setting a cookie was served from the cache")
+	}
+}
+
+func TestHeaderBlockReservedHeaders(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/headers": {"wasm_file": "{echo}", "headers": true},
+		"/stream": {"wasm_file": "{echo}", "headers": true, "stream": true}
+	}}`)
+	h := withRequestID((&SecurityHeaders{}).Middleware(s))
+	out := "X-Frame-Options: ALLOWALL\nX-Content-Type-Options: none\nX-Request-ID: forged\n" +
+		"X-Forwarded-For: 10.0.0.1\nX-Custom: kept\n\nbody"
+
+	for _, path := range []string{"/headers", "/stream"} {
+		w := serve(h, "GET", withOutput(path, out), "X-Request-ID", "client-id")
+		want := map[string]string{
+			"X-Frame-Options":        "DENY",
+			"X-Content-Type-Options": "nosniff",
+			"X-Request-ID":           "client-id",
+			"X-Forwarded-For":        "",
+			"X-Custom":               "kept",
+		}
+		for name, value := range want {
+			if got := w.Header().Values(name); len(got) > 1 || w.Header().Get(name) != value {
+				t.Errorf("%s: %s = %q, want %q", path, name, got, value)
+			}
+		}
+		if w.Body.String() != "body" {
+			t.Errorf("%s: body = %q", path, w.Body)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// healthStatus is the JSON body returned by the health endpoint.
+type healthStatus struct {
+	Status string `json:"status"`
+	// Failing lists the paths of routes whose modules don't compile. The
+	// errors themselves are only logged, as they reveal file system paths.
+	Failing []string `json:"failing,omitempty"`
+}
+
+// wasmFiles lists every WASM file a route may run.
+func (route Route) wasmFiles() []string {
+	files := []string{}
+	if len(route.Variants) == 0 {
+		files = append(files, route.WasmFile)
+	}
+	for _, v := range route.Variants {
+		files = append(files, v.WasmFile)
+	}
+	for _, file := range route.Alternatives {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// checkModules compiles (or fetches from cache) every route's modules and
+// returns the failures keyed by route path.
+func (s *Server) checkModules(ctx context.Context) map[string]string {
+	failing := map[string]string{}
+	for path, route := range s.config.Routes {
+		for _, file := range route.wasmFiles() {
+			if _, err := s.moduleCache.GetCompiledModule(ctx, file); err != nil {
+				failing[path] = err.Error()
+				break
+			}
+		}
+	}
+	return failing
+}
+
+// serveHealth reports liveness. With ?deep=true it also verifies that every
+// route's module compiles, responding 503 with the failing routes otherwise.
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	status, code := healthStatus{Status: "ok"}, http.StatusOK
+	if r.URL.Query().Get("deep") == "true" {
+		if failing := s.checkModules(r.Context()); len(failing) > 0 {
+			status, code = healthStatus{Status: "unhealthy"}, http.StatusServiceUnavailable
+			for path, err := range failing {
+				log.Printf("Health check: route %s failed to compile: %s", path, err)
+				status.Failing = append(status.Failing, path)
+			}
+			sort.Strings(status.Failing)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
+
+// warmUp compiles all modules ahead of the first request, then marks the
+// server ready. Broken modules are logged but don't block readiness, since
+// the other routes can still serve.
+func (s *Server) warmUp(ctx context.Context) {
+	for path, err := range s.checkModules(ctx) {
+		log.Printf("Route %s failed to compile: %s", path, err)
+	}
+	if ctx.Err() == nil {
+		s.ready.Store(true)
+	}
+}
+
+// serveProbe answers liveness (/healthz) and readiness (/readyz) probes.
+// Readiness is false until warm-up completes and again once shutdown begins.
+func (s *Server) serveProbe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if r.URL.Path == "/readyz" && !s.ready.Load() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
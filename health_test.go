@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeepHealth(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.wasm")
+	healthy := newTestServer(t, `{"routes": {
+		"/echo": {"wasm_file": "{echo}"},
+		"/alt": {"wasm_file": "{echo}", "variants": [{"wasm_file": "{alt}", "weight": 1}]}
+	}}`)
+	broken := newTestServer(t, `{"routes": {
+		"/echo": {"wasm_file": "{echo}"},
+		"/broken": {"wasm_file": "{echo}", "alternatives": {"application/json": "`+missing+`"}}
+	}}`)
+
+	tests := []struct {
+		name    string
+		s       *Server
+		target  string
+		want    int
+		failing []string
+	}{
+		{"shallow", broken, "/health", http.StatusOK, nil},
+		{"deep, all healthy", healthy, "/health?deep=true", http.StatusOK, nil},
+		{"deep, one broken", broken, "/health?deep=true", http.StatusServiceUnavailable, []string{"/broken"}},
+	}
+	for _, tt := range tests {
+		w := serve(tt.s, "GET", tt.target)
+		var status healthStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%s: decoding %q: %v", tt.name, w.Body, err)
+		}
+		if w.Code != tt.want || fmt.Sprint(status.Failing) != fmt.Sprint(tt.failing) {
+			t.Errorf("%s: got %d %+v, want %d failing %v", tt.name, w.Code, status, tt.want, tt.failing)
+		}
+		// Compile errors name file system paths, which the response must not reveal
+		if strings.Contains(w.Body.String(), missing) {
+			t.Errorf("%s: response leaks the module path: %s", tt.name, w.Body)
+		}
+	}
+}
+
+func TestProbes(t *testing.T) {
+	s := newTestServer(t, `{"basic_auth": `+basicAuthConfig(t)+`, "routes": {"/echo": {"wasm_file": "{echo}"}}}`)
+	h := stripBasePath("/base", s, s.writeError)
+
+	check := func(phase string, healthz, readyz int) {
+		t.Helper()
+		for target, want := range map[string]int{
+			"/base/healthz":           healthz,
+			"/base/readyz":            readyz,
+			"/base/health":            http.StatusOK,
+			"/base/health?deep=true":  http.StatusUnauthorized,
+			"/base/health?deep=false": http.StatusOK,
+		} {
+			if w := serve(h, "GET", target); w.Code != want {
+				t.Errorf("%s: %s = %d, want %d", phase, target, w.Code, want)
+			}
+		}
+	}
+	// The probes are reachable without credentials, unlike the routes
+	if w := serve(h, "GET", "/base/echo"); w.Code != http.StatusUnauthorized {
+		t.Errorf("/base/echo = %d, want 401", w.Code)
+	}
+	check("starting", http.StatusOK, http.StatusServiceUnavailable)
+	s.warmUp(context.Background())
+	check("warmed up", http.StatusOK, http.StatusOK)
+	// main clears the flag once shutdown begins
+	s.ready.Store(false)
+	check("shutting down", http.StatusOK, http.StatusServiceUnavailable)
+}
+
+func TestProbeRouteTakesPrecedence(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/healthz": {"wasm_file": "{echo}"}}}`)
+	if name := decodeEcho(t, serve(s, "GET", "/healthz")).Name; name != "echo" {
+		t.Errorf("/healthz ran %q, want the configured route", name)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// baseParam returns the named base parameter, validating it lies in 2..36.
+func baseParam(params map[string]string, name string, def int) (int, error) {
+	v, ok := params[name]
+	if !ok || v == "" {
+		return def, nil
+	}
+	base, err := strconv.Atoi(v)
+	if err != nil || base < 2 || base > 36 {
+		return 0, fmt.Errorf("'%s' must be a base between 2 and 36", name)
+	}
+	return base, nil
+}
+
+// parse converts input in the given base to a big integer, reporting the
+// first digit that is not valid for that base.
+func parse(input string, base int) (*big.Int, error) {
+	s := strings.ToLower(strings.TrimSpace(input))
+	body := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	if body == "" {
+		return nil, fmt.Errorf("please provide a number in 'input'")
+	}
+	for i, r := range body {
+		if d := strings.IndexRune(digits, r); d < 0 || d >= base {
+			return nil, fmt.Errorf("invalid digit %q at position %d for base %d", r, i+1, base)
+		}
+	}
+	n, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse %q in base %d", input, base)
+	}
+	return n, nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	params := payload.Params
+	from, err := baseParam(params, "from", 10)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	to, err := baseParam(params, "to", 16)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	n, err := parse(params["input"], from)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if params["mode"] == "bits" {
+		// Show the common representations side by side
+		fmt.Printf("bin: %s\noct: %s\ndec: %s\nhex: %s\n", n.Text(2), n.Text(8), n.Text(10), n.Text(16))
+		fmt.Printf("bit length: %d\n", n.BitLen())
+		return
+	}
+	fmt.Println(n.Text(to))
+}
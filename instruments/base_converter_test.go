@@ -0,0 +1,78 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test base_converter.go base_converter_test.go
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		from, to int
+		want     string
+	}{
+		{"255", 10, 16, "ff"},
+		{"FF", 16, 2, "11111111"},
+		{" -101 ", 2, 10, "-5"},
+		{"+777", 8, 10, "511"},
+		{"zz", 36, 10, "1295"},
+		{"0", 10, 2, "0"},
+		// Far beyond uint64
+		{"18446744073709551616", 10, 16, "10000000000000000"},
+		{"ffffffffffffffffffffffffffffffff", 16, 10, "340282366920938463463374607431768211455"},
+		{strings.Repeat("1", 200), 2, 16, "ff" + strings.Repeat("f", 48)},
+	}
+	for _, tt := range tests {
+		n, err := parse(tt.input, tt.from)
+		if err != nil {
+			t.Errorf("parse(%q, %d): %v", tt.input, tt.from, err)
+			continue
+		}
+		if got := n.Text(tt.to); got != tt.want {
+			t.Errorf("%q from base %d to %d = %s, want %s", tt.input, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		base  int
+		want  string
+	}{
+		{"102", 2, `invalid digit '2' at position 3 for base 2`},
+		{"19", 8, `invalid digit '9' at position 2 for base 8`},
+		{"-1g", 16, `invalid digit 'g' at position 2 for base 16`},
+		{"12.5", 10, `invalid digit '.' at position 3 for base 10`},
+		{"1_000", 10, `invalid digit '_' at position 2 for base 10`},
+		{"0x1f", 16, `invalid digit 'x' at position 2 for base 16`},
+		{"--5", 10, `invalid digit '-' at position 1 for base 10`},
+		{"", 10, "please provide a number"},
+		{"  -", 10, "please provide a number"},
+	}
+	for _, tt := range tests {
+		if _, err := parse(tt.input, tt.base); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parse(%q, %d): got %v, want %q", tt.input, tt.base, err, tt.want)
+		}
+	}
+}
+
+func TestBaseParam(t *testing.T) {
+	if base, err := baseParam(map[string]string{}, "from", 10); err != nil || base != 10 {
+		t.Errorf("default = %d, %v", base, err)
+	}
+	for _, v := range []string{"2", "36"} {
+		if _, err := baseParam(map[string]string{"to": v}, "to", 16); err != nil {
+			t.Errorf("base %s rejected: %v", v, err)
+		}
+	}
+	for _, v := range []string{"1", "37", "0", "-2", "hex"} {
+		if _, err := baseParam(map[string]string{"to": v}, "to", 16); err == nil {
+			t.Errorf("base %s accepted", v)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// rgb is a color with 8-bit channels.
+type rgb struct {
+	R, G, B uint8
+}
+
+// parseColor parses #RRGGBB, #RGB, rgb(r,g,b) and hsl(h,s%,l%) notations.
+func parseColor(input string) (rgb, error) {
+	s := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(input), " ", ""))
+	switch {
+	case strings.HasPrefix(s, "#"):
+		return parseHex(s[1:])
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		vals, err := parseList(s[4:len(s)-1], []float64{255, 255, 255})
+		if err != nil {
+			return rgb{}, err
+		}
+		return rgb{channel(vals[0] / 255), channel(vals[1] / 255), channel(vals[2] / 255)}, nil
+	case strings.HasPrefix(s, "hsl(") && strings.HasSuffix(s, ")"):
+		vals, err := parseList(strings.ReplaceAll(s[4:len(s)-1], "%", ""), []float64{360, 100, 100})
+		if err != nil {
+			return rgb{}, err
+		}
+		return hslToRGB(vals[0], vals[1]/100, vals[2]/100), nil
+	}
+	return rgb{}, fmt.Errorf("unrecognized color %q", input)
+}
+
+// parseHex parses the digits of a #RRGGBB or #RGB color.
+func parseHex(h string) (rgb, error) {
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) != 6 {
+		return rgb{}, fmt.Errorf("hex colors must have 3 or 6 digits")
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return rgb{}, fmt.Errorf("invalid hex color #%s", h)
+	}
+	return rgb{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// parseList parses comma-separated numbers, each checked against 0..limit.
+func parseList(s string, limits []float64) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != len(limits) {
+		return nil, fmt.Errorf("expected %d components, got %d", len(limits), len(parts))
+	}
+	vals := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || math.IsNaN(v) {
+			return nil, fmt.Errorf("invalid component %q", p)
+		}
+		if v < 0 || v > limits[i] {
+			return nil, fmt.Errorf("component %q out of range 0-%g", p, limits[i])
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+// hslToRGB converts hue in degrees and saturation/lightness in [0,1].
+func hslToRGB(h, s, l float64) rgb {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := math.Mod(h, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	m := l - c/2
+	return rgb{channel(r + m), channel(g + m), channel(b + m)}
+}
+
+// channel scales a [0,1] value to a clamped 8-bit channel.
+func channel(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
+// toHSL returns hue in degrees and saturation/lightness as percentages.
+func (c rgb) toHSL() (float64, float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (hi + lo) / 2
+	if hi == lo {
+		return 0, 0, l * 100
+	}
+	d := hi - lo
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s * 100, l * 100
+}
+
+// luminance returns the WCAG relative luminance of the color.
+func (c rgb) luminance() float64 {
+	lin := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.03928 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	return 0.2126*lin(c.R) + 0.7152*lin(c.G) + 0.0722*lin(c.B)
+}
+
+// contrast returns the WCAG contrast ratio between two luminances.
+func contrast(a, b float64) float64 {
+	return (math.Max(a, b) + 0.05) / (math.Min(a, b) + 0.05)
+}
+
+// format renders c in the target notation.
+func format(c rgb, to string) (string, error) {
+	switch to {
+	case "", "hex":
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B), nil
+	case "rgb":
+		return fmt.Sprintf("rgb(%d, %d, %d)", c.R, c.G, c.B), nil
+	case "hsl":
+		h, s, l := c.toHSL()
+		return fmt.Sprintf("hsl(%.0f, %.0f%%, %.0f%%)", h, s, l), nil
+	}
+	return "", fmt.Errorf("unknown target format '%s'. Supported: hex, rgb, hsl", to)
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	c, err := parseColor(payload.Params["input"])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	out, err := format(c, payload.Params["to"])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(out)
+
+	if payload.Params["report"] == "true" {
+		lum := c.luminance()
+		fmt.Printf("Relative luminance: %.4f\n", lum)
+		fmt.Printf("Contrast vs white: %.2f:1\n", contrast(lum, 1))
+		fmt.Printf("Contrast vs black: %.2f:1\n", contrast(lum, 0))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test color_converter.go color_converter_test.go
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input string
+		want  rgb
+	}{
+		{"#ff8000", rgb{255, 128, 0}},
+		{"  #FF8000 ", rgb{255, 128, 0}},
+		{"#f80", rgb{255, 136, 0}},
+		{"rgb(255, 128, 0)", rgb{255, 128, 0}},
+		{"RGB(0,0,0)", rgb{0, 0, 0}},
+		{"rgb(127.6, 0, 0)", rgb{128, 0, 0}},
+		{"hsl(0, 100%, 50%)", rgb{255, 0, 0}},
+		{"hsl(120, 100%, 25%)", rgb{0, 128, 0}},
+		{"hsl(240,100,50)", rgb{0, 0, 255}},
+		{"hsl(360, 100%, 50%)", rgb{255, 0, 0}},
+		{"hsl(0, 0%, 100%)", rgb{255, 255, 255}},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("parseColor(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for r := 0; r < 256; r += 15 {
+		for g := 0; g < 256; g += 17 {
+			for b := 0; b < 256; b += 51 {
+				c := rgb{uint8(r), uint8(g), uint8(b)}
+				for _, to := range []string{"hex", "rgb", "hsl"} {
+					out, err := format(c, to)
+					if err != nil {
+						t.Fatal(err)
+					}
+					back, err := parseColor(out)
+					if err != nil {
+						t.Fatalf("%v as %s = %q doesn't parse: %v", c, to, out, err)
+					}
+					// HSL is printed in whole degrees and percent
+					tolerance := 0
+					if to == "hsl" {
+						tolerance = 3
+					}
+					if diff(back.R, c.R) > tolerance || diff(back.G, c.G) > tolerance || diff(back.B, c.B) > tolerance {
+						t.Errorf("%v as %s = %q parses back as %v", c, to, out, back)
+					}
+				}
+			}
+		}
+	}
+	if out, _ := format(rgb{255, 0, 0}, "hsl"); out != "hsl(0, 100%, 50%)" {
+		t.Errorf("red in hsl = %q", out)
+	}
+	if out, _ := format(rgb{18, 52, 86}, ""); out != "#123456" {
+		t.Errorf("default format = %q, want hex", out)
+	}
+}
+
+// diff returns the distance between two channel values.
+func diff(a, b uint8) int {
+	return max(int(a)-int(b), int(b)-int(a))
+}
+
+func TestParseColorMalformed(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"", "unrecognized color"},
+		{"red", "unrecognized color"},
+		{"ff8000", "unrecognized color"},
+		{"rgb(1, 2, 3", "unrecognized color"},
+		{"#ff80", "3 or 6 digits"},
+		{"#ff80000", "3 or 6 digits"},
+		{"#gg8000", "invalid hex color"},
+		{"#+12345", "invalid hex color"},
+		{"rgb(1, 2)", "expected 3 components, got 2"},
+		{"rgb(1, 2, 3, 4)", "expected 3 components, got 4"},
+		{"rgb(256, 0, 0)", "out of range 0-255"},
+		{"rgb(-1, 0, 0)", "out of range 0-255"},
+		{"rgb(x, 0, 0)", "invalid component"},
+		{"rgb(NaN, 0, 0)", "invalid component"},
+		{"rgb(inf, 0, 0)", "out of range"},
+		{"hsl(361, 50%, 50%)", "out of range 0-360"},
+		{"hsl(0, 101%, 50%)", "out of range 0-100"},
+	}
+	for _, tt := range tests {
+		if c, err := parseColor(tt.input); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseColor(%q) = %v, %v; want %q", tt.input, c, err, tt.want)
+		}
+	}
+	if _, err := format(rgb{}, "cmyk"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -3,19 +3,141 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"strconv"
+	"strings"
 )
 
+// maxSeqN bounds n in sequence mode to keep the output size reasonable.
+const maxSeqN = 1000
+
 type Payload struct {
 	Params map[string]string `json:"params"`
 }
 
-func fibonacci(n int) int {
-	if n <= 1 {
-		return n
+// sequence describes an integer sequence the instrument can compute.
+type sequence struct {
+	first int    // index of the first term
+	max   int    // largest supported n
+	label string // format for a single term, given n and the term
+	// terms returns a generator yielding the terms from index first upwards.
+	// The returned value may be reused by the next call, so copy it to keep it.
+	terms func() func() *big.Int
+}
+
+// sequences maps the values of the 'seq' parameter to their sequence.
+var sequences = map[string]sequence{
+	"fib":        {0, 100000, "Fibonacci number for n=%d is %s", fibonacciTerms},
+	"lucas":      {0, 100000, "Lucas number for n=%d is %s", lucasTerms},
+	"factorial":  {0, 10000, "Factorial of n=%d is %s", factorialTerms},
+	"triangular": {0, 1000000, "Triangular number for n=%d is %s", triangularTerms},
+	"primes":     {1, 100000, "Prime number n=%d is %s", primeTerms},
+}
+
+// recurrence yields a, b, a+b, ... computed iteratively with arbitrary precision.
+func recurrence(a, b int64) func() *big.Int {
+	x, y := big.NewInt(a), big.NewInt(b)
+	started := false
+	return func() *big.Int {
+		// Advance lazily so the returned term stays valid until the next call
+		if started {
+			x.Add(x, y)
+			x, y = y, x
+		}
+		started = true
+		return x
+	}
+}
+
+// fibonacciTerms yields F(0), F(1), ...
+func fibonacciTerms() func() *big.Int { return recurrence(0, 1) }
+
+// lucasTerms yields L(0), L(1), ...
+func lucasTerms() func() *big.Int { return recurrence(2, 1) }
+
+// factorialTerms yields 0!, 1!, 2!, ...
+func factorialTerms() func() *big.Int {
+	f, k := big.NewInt(1), int64(0)
+	return func() *big.Int {
+		if k > 0 {
+			f.Mul(f, big.NewInt(k))
+		}
+		k++
+		return f
+	}
+}
+
+// triangularTerms yields T(0), T(1), ... where T(n) = n(n+1)/2.
+func triangularTerms() func() *big.Int {
+	t, k := big.NewInt(0), int64(0)
+	return func() *big.Int {
+		t.Add(t, big.NewInt(k))
+		k++
+		return t
+	}
+}
+
+// primeTerms yields 2, 3, 5, ... by trial division against the primes found so far.
+func primeTerms() func() *big.Int {
+	var primes []int64
+	return func() *big.Int {
+		candidate := int64(2)
+		if len(primes) > 0 {
+			candidate = primes[len(primes)-1] + 1
+		}
+		for ; ; candidate++ {
+			isPrime := true
+			for _, p := range primes {
+				if p*p > candidate {
+					break
+				}
+				if candidate%p == 0 {
+					isPrime = false
+					break
+				}
+			}
+			if isPrime {
+				primes = append(primes, candidate)
+				return big.NewInt(candidate)
+			}
+		}
+	}
+}
+
+// nth returns term n of seq.
+func nth(seq sequence, n int) *big.Int {
+	next := seq.terms()
+	term := next()
+	for i := seq.first; i < n; i++ {
+		term = next()
 	}
-	return fibonacci(n-1) + fibonacci(n-2)
+	return term
+}
+
+// list returns the terms of seq from its first index up to n.
+func list(seq sequence, n int) []*big.Int {
+	next := seq.terms()
+	terms := make([]*big.Int, 0, n-seq.first+1)
+	for i := seq.first; i <= n; i++ {
+		terms = append(terms, new(big.Int).Set(next()))
+	}
+	return terms
+}
+
+// formatList renders terms as a JSON array for format "json", and as
+// comma-separated values otherwise.
+func formatList(terms []*big.Int, format string) string {
+	if format == "json" {
+		// big.Int marshals as a JSON number, so large terms stay exact
+		data, _ := json.Marshal(terms)
+		return string(data)
+	}
+	strs := make([]string, len(terms))
+	for i, t := range terms {
+		strs[i] = t.String()
+	}
+	return strings.Join(strs, ",")
 }
 
 func main() {
@@ -26,14 +148,41 @@ func main() {
 		return
 	}
 
+	// 'seq=true' predates named sequences and lists Fibonacci numbers
+	params := payload.Params
+	name, listMode := params["seq"], params["list"] == "true"
+	if name == "true" {
+		name, listMode = "fib", true
+	}
+	if name == "" {
+		name = "fib"
+	}
+	seq, ok := sequences[name]
+	if !ok {
+		fmt.Println("Unknown sequence. Supported: fib, lucas, factorial, triangular, primes")
+		return
+	}
+
 	// Parse the "n" parameter from the payload
-	n, err := strconv.Atoi(payload.Params["n"])
-	if err != nil || n < 0 {
-		fmt.Println("Please provide a valid non-negative integer for 'n'.")
+	n, err := strconv.Atoi(params["n"])
+	if err != nil || n < seq.first {
+		fmt.Printf("Please provide a valid integer of at least %d for 'n'.\n", seq.first)
+		return
+	}
+	if n > seq.max {
+		fmt.Printf("The %s sequence supports n up to %d.\n", name, seq.max)
+		return
+	}
+
+	if listMode {
+		if n > maxSeqN {
+			fmt.Printf("Sequence mode supports n up to %d.\n", maxSeqN)
+			return
+		}
+		fmt.Println(formatList(list(seq, n), params["format"]))
 		return
 	}
 
-	// Compute and print the Fibonacci number
-	result := fibonacci(n)
-	fmt.Printf("Fibonacci number for n=%d is %d\n", n, result)
+	// Compute and print the requested term
+	fmt.Printf(seq.label+"\n", n, nth(seq, n).String())
 }
@@ -0,0 +1,89 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test fibonacci.go fibonacci_test.go
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFibonacci(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{2, "1"},
+		{10, "55"},
+		// Beyond uint64, so only exact with arbitrary precision
+		{100, "354224848179261915075"},
+	}
+	for _, tt := range tests {
+		if got := nth(sequences["fib"], tt.n).String(); got != tt.want {
+			t.Errorf("F(%d) = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	terms := list(sequences["fib"], 10)
+	if got, want := formatList(terms, ""), "0,1,1,2,3,5,8,13,21,34,55"; got != want {
+		t.Errorf("csv = %s, want %s", got, want)
+	}
+	if got, want := formatList(terms, "json"), "[0,1,1,2,3,5,8,13,21,34,55]"; got != want {
+		t.Errorf("json = %s, want %s", got, want)
+	}
+	if got := formatList(list(sequences["fib"], 0), "json"); got != "[0]" {
+		t.Errorf("n=0: json = %s, want [0]", got)
+	}
+
+	// Terms past 2^53 stay exact in JSON
+	var decoded []json.Number
+	if err := json.Unmarshal([]byte(formatList(list(sequences["fib"], 100), "json")), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 101 || decoded[100] != "354224848179261915075" {
+		t.Errorf("json list has %d terms ending in %s", len(decoded), decoded[len(decoded)-1])
+	}
+	// Listed terms are copies, not the generator's reused value
+	if terms[9].String() != "34" {
+		t.Errorf("term 9 = %s, want 34", terms[9])
+	}
+}
+
+func TestSequences(t *testing.T) {
+	tests := []struct {
+		seq  string
+		n    int
+		want string
+	}{
+		{"lucas", 0, "2"},
+		{"lucas", 1, "1"},
+		{"lucas", 10, "123"},
+		{"lucas", 50, "28143753123"},
+		{"factorial", 0, "1"},
+		{"factorial", 1, "1"},
+		{"factorial", 5, "120"},
+		{"factorial", 25, "15511210043330985984000000"},
+		{"triangular", 0, "0"},
+		{"triangular", 1, "1"},
+		{"triangular", 100, "5050"},
+		{"triangular", 1000000, "500000500000"},
+		// Primes are counted from 1
+		{"primes", 1, "2"},
+		{"primes", 2, "3"},
+		{"primes", 10, "29"},
+		{"primes", 1000, "7919"},
+	}
+	for _, tt := range tests {
+		if got := nth(sequences[tt.seq], tt.n).String(); got != tt.want {
+			t.Errorf("%s n=%d = %s, want %s", tt.seq, tt.n, got, tt.want)
+		}
+	}
+	if got := formatList(list(sequences["primes"], 5), ""); got != "2,3,5,7,11" {
+		t.Errorf("first five primes = %s", got)
+	}
+}
@@ -2,19 +2,166 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
+// dataDir is where the server mounts the instrument's filesystem. Tests
+// point it at a temporary directory.
+var dataDir = "/data"
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// fileStats holds wc-style counts for a file.
+type fileStats struct {
+	File   string `json:"file"`
+	Lines  int    `json:"lines"`
+	Words  int    `json:"words"`
+	Bytes  int    `json:"bytes"`
+	Chars  int    `json:"chars"`
+	Binary bool   `json:"binary,omitempty"`
+}
+
+// String formats the counts like a line of wc output.
+func (fs fileStats) String() string {
+	return fmt.Sprintf("%d lines, %d words, %d bytes, %d chars: %s",
+		fs.Lines, fs.Words, fs.Bytes, fs.Chars, fs.File)
+}
+
+// resolvePath maps a file name relative to the mount to an absolute path,
+// rejecting anything that would escape the mounted directory.
+func resolvePath(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file path %q", name)
+	}
+	return path.Join(dataDir, cleaned), nil
+}
+
+// countStats computes line, word, byte and character counts like wc.
+func countStats(name string, content []byte) fileStats {
+	return fileStats{
+		File:   name,
+		Lines:  bytes.Count(content, []byte("\n")),
+		Words:  len(bytes.Fields(content)),
+		Bytes:  len(content),
+		Chars:  utf8.RuneCount(content),
+		Binary: bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content),
+	}
+}
+
+// processGlob returns counts for every file matching pattern.
+func processGlob(pattern string) ([]fileStats, error) {
+	resolved, err := resolvePath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %v", pattern, err)
+	}
+	sort.Strings(matches)
+
+	var results []fileStats
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(match)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", match, err)
+		}
+		results = append(results, countStats(strings.TrimPrefix(match, dataDir+"/"), content))
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no files match %q", pattern)
+	}
+	return results, nil
+}
+
+// total sums the counts of results into a wc-style total line.
+func total(results []fileStats) fileStats {
+	sum := fileStats{File: fmt.Sprintf("total (%d files)", len(results))}
+	for _, stats := range results {
+		sum.Lines += stats.Lines
+		sum.Words += stats.Words
+		sum.Bytes += stats.Bytes
+		sum.Chars += stats.Chars
+	}
+	return sum
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(data))
+}
+
 func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+	asJSON := payload.Params["format"] == "json"
+
+	if pattern := payload.Params["glob"]; pattern != "" {
+		results, err := processGlob(pattern)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if asJSON {
+			printJSON(results)
+			return
+		}
+		for _, stats := range results {
+			fmt.Println(stats)
+		}
+		fmt.Println(total(results))
+		return
+	}
+
+	name := payload.Params["file"]
+	if name == "" {
+		name = "input.txt"
+	}
+	filePath, err := resolvePath(name)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
 	// Open and read the file
-	fileContent, err := ioutil.ReadFile("/data/input.txt")
+	fileContent, err := os.ReadFile(filePath)
 	if err != nil {
 		fmt.Println("Error reading file:", err)
 		return
 	}
 
-	// Process the file content (e.g., counting lines)
-	lines := len(bytes.Split(fileContent, []byte("\n")))
-	fmt.Printf("File has %d lines.\nContent: %s", lines, fileContent)
+	stats := countStats(name, fileContent)
+	if asJSON {
+		printJSON(stats)
+		return
+	}
+	fmt.Println(stats)
+
+	if payload.Params["content"] == "false" {
+		return
+	}
+	if stats.Binary {
+		fmt.Println("Content: (binary, not shown)")
+		return
+	}
+	fmt.Printf("Content: %s", fileContent)
 }
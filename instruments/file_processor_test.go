@@ -0,0 +1,164 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test file_processor.go file_processor_test.go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useDataDir points the instrument at a temporary mount holding files.
+func useDataDir(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := dataDir
+	dataDir = dir
+	t.Cleanup(func() { dataDir = old })
+}
+
+// poem has 3 lines and 13 words in 71 characters; Ü and — take 5 bytes.
+const poem = "Über den Wolken\nmuss die Freiheit wohl\ngrenzenlos sein, sagt man — ja.\n"
+
+func TestCountStats(t *testing.T) {
+	useDataDir(t, map[string]string{"poem.txt": poem})
+	path, err := resolvePath("poem.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := countStats("poem.txt", content)
+	want := fileStats{File: "poem.txt", Lines: 3, Words: 13, Bytes: 74, Chars: 71}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if got, want := stats.String(), "3 lines, 13 words, 74 bytes, 71 chars: poem.txt"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// No trailing newline means one line less, like wc
+	if stats := countStats("x", []byte("a b\nc")); stats.Lines != 1 || stats.Words != 3 {
+		t.Errorf("unterminated line: %+v", stats)
+	}
+	for _, content := range []string{"PNG\x00\x01", "caf\xe9"} {
+		if !countStats("bin", []byte(content)).Binary {
+			t.Errorf("%q not detected as binary", content)
+		}
+	}
+	if countStats("empty", nil) != (fileStats{File: "empty"}) {
+		t.Error("empty file has counts")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	useDataDir(t, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	for name, want := range map[string]string{
+		"a.txt":           "a.txt",
+		"sub/b.txt":       "sub/b.txt",
+		"./sub/../a.txt":  "a.txt",
+		"sub/../../a.txt": "",
+		"../etc/passwd":   "",
+		"..":              "",
+		"/etc/passwd":     "",
+	} {
+		got, err := resolvePath(name)
+		switch {
+		case want == "" && err == nil:
+			t.Errorf("%q escapes the mount as %s", name, got)
+		case want != "" && (err != nil || got != filepath.Join(dataDir, want)):
+			t.Errorf("resolvePath(%q) = %q, %v; want %s in the mount", name, got, err, want)
+		}
+	}
+
+	path, _ := resolvePath("missing.txt")
+	if _, err := os.ReadFile(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v", err)
+	}
+}
+
+func TestProcessGlob(t *testing.T) {
+	useDataDir(t, map[string]string{
+		"b.txt":        "one two\nthree\n",
+		"a.txt":        "hello\n",
+		"notes.md":     "# not matched\n",
+		"dir.txt/x":    "directories are skipped",
+		"sub/deep.txt": "not matched either\n",
+	})
+	results, err := processGlob("*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileStats{
+		{File: "a.txt", Lines: 1, Words: 1, Bytes: 6, Chars: 6},
+		{File: "b.txt", Lines: 2, Words: 3, Bytes: 14, Chars: 14},
+	}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("*.txt = %v, want %v", results, want)
+	}
+	if got, want := total(results).String(), "3 lines, 4 words, 20 bytes, 20 chars: total (2 files)"; got != want {
+		t.Errorf("total = %q, want %q", got, want)
+	}
+
+	if results, err := processGlob("sub/*.txt"); err != nil || len(results) != 1 || results[0].File != "sub/deep.txt" {
+		t.Errorf("sub/*.txt = %v, %v", results, err)
+	}
+	for _, pattern := range []string{"*.csv", "../*", "/etc/*", "[", "dir.txt"} {
+		if results, err := processGlob(pattern); err == nil {
+			t.Errorf("%q matched %v", pattern, results)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	stats := countStats("poem.txt", []byte(poem))
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"file":"poem.txt","lines":3,"words":13,"bytes":74,"chars":71}`; got != want {
+		t.Errorf("json = %s, want %s", got, want)
+	}
+	data, _ = json.Marshal(countStats("bin", []byte{0, 1}))
+	if !strings.Contains(string(data), `"binary":true`) {
+		t.Errorf("binary file json = %s", data)
+	}
+
+	// JSON and text output report the same counts
+	useDataDir(t, map[string]string{"a.txt": "x y\n", "b.txt": poem})
+	results, _ := processGlob("*.txt")
+	data, _ = json.Marshal(results)
+	var list []fileStats
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(results) {
+		t.Fatalf("json has %d entries, want %d", len(list), len(results))
+	}
+	for i, line := range results {
+		var text fileStats
+		if _, err := fmt.Sscanf(line.String(), "%d lines, %d words, %d bytes, %d chars: %s", &text.Lines, &text.Words, &text.Bytes, &text.Chars, &text.File); err != nil {
+			t.Fatalf("parsing %q: %v", line, err)
+		}
+		if list[i] != text {
+			t.Errorf("json %+v, text mode %+v", list[i], text)
+		}
+	}
+}
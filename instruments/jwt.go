@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// Report describes a decoded token. Claims are only trustworthy when
+// Verification is "valid".
+type Report struct {
+	Algorithm    string          `json:"algorithm"`
+	Verification string          `json:"verification"`
+	Header       json.RawMessage `json:"header"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// decodeSegment base64url-decodes a token segment and checks it is a JSON object.
+func decodeSegment(segment, name string) (json.RawMessage, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64url: %v", name, err)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON object: %v", name, err)
+	}
+	return json.RawMessage(data), nil
+}
+
+// inspect decodes token and, if key is non-empty, verifies an HS256 signature.
+func inspect(token, key string) (*Report, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token must have 3 dot-separated parts, got %d", len(parts))
+	}
+	header, err := decodeSegment(parts[0], "header")
+	if err != nil {
+		return nil, err
+	}
+	claims, err := decodeSegment(parts[1], "payload")
+	if err != nil {
+		return nil, err
+	}
+
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	json.Unmarshal(header, &h)
+	report := &Report{Algorithm: h.Alg, Header: header, Payload: claims, Verification: "unverified"}
+
+	if key == "" {
+		return report, nil
+	}
+	if h.Alg != "HS256" {
+		report.Verification = fmt.Sprintf("unsupported algorithm %q", h.Alg)
+		return report, nil
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		report.Verification = "invalid"
+		return report, nil
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if hmac.Equal(sig, mac.Sum(nil)) {
+		report.Verification = "valid"
+	} else {
+		report.Verification = "invalid"
+	}
+	return report, nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	token := payload.Params["token"]
+	if token == "" {
+		fmt.Println("Please provide a 'token' parameter.")
+		return
+	}
+
+	report, err := inspect(token, payload.Params["key"])
+	if err != nil {
+		fmt.Println("Error: malformed token:", err)
+		return
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+}
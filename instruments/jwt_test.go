@@ -0,0 +1,79 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test jwt.go jwt_test.go
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// exampleToken is the HS256 example from jwt.io, signed with exampleKey.
+const (
+	exampleToken = "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		"eyJzdWIiOiIxMjM0NTY3ODkwIiwibmFtZSI6IkpvaG4gRG9lIiwiaWF0IjoxNTE2MjM5MDIyfQ." +
+		"SflKxwRJSMeKKF2QT4fwpMeJf36POk6yJV_adQssw5c"
+	exampleKey = "your-256-bit-secret"
+)
+
+func TestInspectValid(t *testing.T) {
+	report, err := inspect(exampleToken, exampleKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verification != "valid" || report.Algorithm != "HS256" {
+		t.Errorf("got %s %s, want a valid HS256 token", report.Algorithm, report.Verification)
+	}
+	if got, want := string(report.Payload), `{"sub":"1234567890","name":"John Doe","iat":1516239022}`; got != want {
+		t.Errorf("payload = %s, want %s", got, want)
+	}
+
+	// Without a key the claims are decoded but not trusted
+	if report, _ := inspect(exampleToken, ""); report.Verification != "unverified" {
+		t.Errorf("no key: %s", report.Verification)
+	}
+}
+
+func TestInspectTampered(t *testing.T) {
+	parts := strings.Split(exampleToken, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234567890","name":"John Doe","admin":true}`))
+	tests := map[string]string{
+		"changed claims":    parts[0] + "." + forged + "." + parts[2],
+		"changed signature": parts[0] + "." + parts[1] + "." + strings.Replace(parts[2], "S", "T", 1),
+		"no signature":      parts[0] + "." + parts[1] + ".",
+		"bad signature":     parts[0] + "." + parts[1] + ".!!!",
+	}
+	for name, token := range tests {
+		report, err := inspect(token, exampleKey)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if report.Verification != "invalid" {
+			t.Errorf("%s: %s, want invalid", name, report.Verification)
+		}
+	}
+	if report, _ := inspect(exampleToken, "wrong-secret"); report.Verification != "invalid" {
+		t.Errorf("wrong key: %s, want invalid", report.Verification)
+	}
+
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	if report, _ := inspect(none+"."+parts[1]+".", exampleKey); report.Verification != `unsupported algorithm "none"` {
+		t.Errorf("alg none: %s", report.Verification)
+	}
+}
+
+func TestInspectMalformed(t *testing.T) {
+	for token, want := range map[string]string{
+		"abc":               "3 dot-separated parts, got 1",
+		"a.b.c.d":           "3 dot-separated parts, got 4",
+		"!!!.e30.x":         "header is not valid base64url",
+		"e30.bm90IGpzb24.x": "payload is not a JSON object",
+		"WzEsMl0.e30.x":     "header is not a JSON object",
+	} {
+		if _, err := inspect(token, ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", token, err, want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// palettes maps palette names to functions coloring an escape iteration.
+var palettes = map[string]func(iter float64, maxIter int) color.RGBA{
+	"grayscale": mapColor,
+	"fire":      fireColor,
+	"ocean":     oceanColor,
+	"rainbow":   rainbowColor,
+}
+
+// envInt returns the environment variable name as an int, or def if unset or invalid.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// intParam returns the named parameter as an int, or def if absent or invalid.
+func intParam(params map[string]string, name string, def int) int {
+	if v, err := strconv.Atoi(params[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// floatParam returns the named parameter as a float64, or def if absent or invalid.
+func floatParam(params map[string]string, name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(params[name], 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// mapCoord maps pixel (px, py) to a point on the complex plane.
+func mapCoord(px, py, width, height int, cx, cy, zoom float64) (float64, float64) {
+	scale := 3.0 / (zoom * float64(min(width, height)))
+	return cx + (float64(px)-float64(width)/2)*scale, cy + (float64(py)-float64(height)/2)*scale
+}
+
+// escape iterates z -> z^2 + c from z and returns the iteration at which it
+// escapes, or maxIter if it doesn't. With smooth set, the count is the
+// normalized iteration count: the final magnitude of z interpolates between
+// whole iterations, so colors no longer form discrete bands.
+func escape(zx, zy, cx, cy float64, maxIter int, smooth bool) float64 {
+	// A large bailout radius makes the normalized count more accurate
+	bailout := 4.0
+	if smooth {
+		bailout = 1 << 16
+	}
+	for i := 0; i < maxIter; i++ {
+		zx2, zy2 := zx*zx, zy*zy
+		if zx2+zy2 > bailout {
+			if !smooth {
+				return float64(i)
+			}
+			nu := float64(i) + 1 - math.Log2(math.Log(zx2+zy2)/2)
+			return math.Max(0, nu)
+		}
+		zy = 2*zx*zy + cy
+		zx = zx2 - zy2 + cx
+	}
+	return float64(maxIter)
+}
+
+// mapColor maps an iteration count to a blue-tinted grayscale.
+func mapColor(iter float64, maxIter int) color.RGBA {
+	if iter >= float64(maxIter) {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	c := uint8(255 * iter / float64(maxIter))
+	return color.RGBA{c, c, uint8(min(255, int(c)+64)), 255}
+}
+
+// fireColor ramps from black through red and yellow to white.
+func fireColor(iter float64, maxIter int) color.RGBA {
+	if iter >= float64(maxIter) {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	t := iter / float64(maxIter)
+	return color.RGBA{
+		uint8(255 * math.Min(1, 3*t)),
+		uint8(255 * math.Min(1, math.Max(0, 3*t-1))),
+		uint8(255 * math.Max(0, 3*t-2)),
+		255,
+	}
+}
+
+// oceanColor ramps from deep navy through teal to pale cyan.
+func oceanColor(iter float64, maxIter int) color.RGBA {
+	if iter >= float64(maxIter) {
+		return color.RGBA{0, 0, 32, 255}
+	}
+	t := iter / float64(maxIter)
+	return color.RGBA{uint8(64 * t), uint8(255 * math.Sqrt(t)), uint8(128 + 127*t), 255}
+}
+
+// rainbowColor cycles the hue with the iteration count at full saturation.
+func rainbowColor(iter float64, maxIter int) color.RGBA {
+	if iter >= float64(maxIter) {
+		return color.RGBA{0, 0, 0, 255}
+	}
+	return hsvToRGB(360*iter/float64(maxIter), 1, 1)
+}
+
+// hsvToRGB converts a hue in degrees and saturation/value in [0,1] to RGBA.
+func hsvToRGB(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{uint8(255 * (r + m)), uint8(255 * (g + m)), uint8(255 * (b + m)), 255}
+}
+
+// fractal holds the parameters needed to render an image.
+type fractal struct {
+	width, height, maxIter int
+	zoom, centerX, centerY float64
+	julia, smooth          bool
+	jx, jy                 float64
+	colorize               func(iter float64, maxIter int) color.RGBA
+}
+
+// renderRows renders rows [y0, y1) of the fractal into img.
+func (f *fractal) renderRows(img *image.RGBA, y0, y1 int) {
+	for py := y0; py < y1; py++ {
+		for px := 0; px < f.width; px++ {
+			x, y := mapCoord(px, py, f.width, f.height, f.centerX, f.centerY, f.zoom)
+			var iter float64
+			if f.julia {
+				iter = escape(x, y, f.jx, f.jy, f.maxIter, f.smooth)
+			} else {
+				iter = escape(0, 0, x, y, f.maxIter, f.smooth)
+			}
+			img.SetRGBA(px, py, f.colorize(iter, f.maxIter))
+		}
+	}
+}
+
+// render draws the fractal, splitting rows across workers goroutines.
+// Each worker writes a disjoint band of rows, so no locking is needed.
+func (f *fractal) render(workers int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	if workers <= 1 {
+		f.renderRows(img, 0, f.height)
+		return img
+	}
+
+	band := (f.height + workers - 1) / workers
+	var wg sync.WaitGroup
+	for y0 := 0; y0 < f.height; y0 += band {
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			f.renderRows(img, y0, y1)
+		}(y0, min(y0+band, f.height))
+	}
+	wg.Wait()
+	return img
+}
+
+// encode writes img to out in the given format.
+func encode(out io.Writer, img image.Image, format string, params map[string]string) error {
+	switch format {
+	case "jpeg":
+		quality := intParam(params, "quality", jpeg.DefaultQuality)
+		return jpeg.Encode(out, img, &jpeg.Options{Quality: max(1, min(quality, 100))})
+	case "gif":
+		return gif.Encode(out, img, nil)
+	}
+	return png.Encode(out, img)
+}
+
+// renderBands renders the image in horizontal bands of up to band rows and
+// writes each one as soon as it is done, as a part of a multipart/mixed
+// stream preceded by a header block naming the boundary. Every part carries
+// its position so a client can reassemble the full image.
+func (f *fractal) renderBands(out *bufio.Writer, band int, format string, params map[string]string, meta textproto.MIMEHeader) error {
+	// The random boundary can't collide with the encoded image data
+	mw := multipart.NewWriter(out)
+	block := textproto.MIMEHeader{"Content-Type": {"multipart/mixed; boundary=" + mw.Boundary()}}
+	for key, values := range meta {
+		block[key] = values
+	}
+	writeHeaders(out, block)
+	if err := out.Flush(); err != nil {
+		return err
+	}
+	for y0 := 0; y0 < f.height; y0 += band {
+		y1 := min(y0+band, f.height)
+		img := image.NewRGBA(image.Rect(0, y0, f.width, y1))
+		f.renderRows(img, y0, y1)
+		header := textproto.MIMEHeader{
+			"Content-Type":   {"image/" + format},
+			"X-Tile-Y":       {strconv.Itoa(y0)},
+			"X-Tile-Height":  {strconv.Itoa(y1 - y0)},
+			"X-Image-Width":  {strconv.Itoa(f.width)},
+			"X-Image-Height": {strconv.Itoa(f.height)},
+		}
+		for key, values := range meta {
+			header[key] = values
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if err := encode(part, img, format, params); err != nil {
+			return err
+		}
+		// Hand each band to the server right away, which streams it on
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// metadata describes the parameters actually used for rendering, after
+// defaults and clamping, as X-Mandelbrot-* headers.
+func (f *fractal) metadata(palette string) textproto.MIMEHeader {
+	kind := "mandelbrot"
+	if f.julia {
+		kind = "julia"
+	}
+	float := func(v float64) []string { return []string{strconv.FormatFloat(v, 'g', -1, 64)} }
+	meta := textproto.MIMEHeader{
+		"X-Mandelbrot-Type":    {kind},
+		"X-Mandelbrot-Width":   {strconv.Itoa(f.width)},
+		"X-Mandelbrot-Height":  {strconv.Itoa(f.height)},
+		"X-Mandelbrot-Iter":    {strconv.Itoa(f.maxIter)},
+		"X-Mandelbrot-Zoom":    float(f.zoom),
+		"X-Mandelbrot-X":       float(f.centerX),
+		"X-Mandelbrot-Y":       float(f.centerY),
+		"X-Mandelbrot-Palette": {palette},
+		"X-Mandelbrot-Smooth":  {strconv.FormatBool(f.smooth)},
+	}
+	if f.julia {
+		meta["X-Mandelbrot-Jx"], meta["X-Mandelbrot-Jy"] = float(f.jx), float(f.jy)
+	}
+	return meta
+}
+
+// writeHeaders writes meta as a CGI-style header block ended by a blank line.
+func writeHeaders(out io.Writer, meta textproto.MIMEHeader) {
+	keys := make([]string, 0, len(meta))
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s: %s\r\n", key, meta.Get(key))
+	}
+	fmt.Fprint(out, "\r\n")
+}
+
+// renderWorkers returns the number of goroutines to render with. It follows
+// the CPUs the runtime reports, so a guest without wasm threads renders
+// sequentially and one with them splits the rows, unless MANDELBROT_WORKERS
+// sets the number explicitly.
+func renderWorkers() int {
+	return envInt("MANDELBROT_WORKERS", runtime.NumCPU())
+}
+
+// parseFractal builds the fractal a request asks for, applying defaults
+// and limits, and returns it with the palette and image format names.
+func parseFractal(params map[string]string) (f *fractal, palette, format string, err error) {
+	f = &fractal{
+		width:   intParam(params, "width", 640),
+		height:  intParam(params, "height", 480),
+		maxIter: intParam(params, "max_iter", 100),
+		zoom:    floatParam(params, "zoom", 1),
+	}
+
+	// Julia sets use a fixed constant c and are centered on the origin by default
+	f.julia = params["type"] == "julia"
+	if params["type"] != "" && params["type"] != "mandelbrot" && !f.julia {
+		return nil, "", "", errors.New("Unknown type. Supported: mandelbrot, julia")
+	}
+	defaultX := -0.5
+	if f.julia {
+		defaultX = 0
+	}
+	f.centerX = floatParam(params, "x", defaultX)
+	f.centerY = floatParam(params, "y", 0)
+	f.jx = floatParam(params, "jx", -0.8)
+	f.jy = floatParam(params, "jy", 0.156)
+	f.smooth = params["smooth"] != "false"
+
+	if f.width <= 0 || f.height <= 0 || f.maxIter <= 0 || f.zoom <= 0 {
+		return nil, "", "", errors.New("Please provide positive values for 'width', 'height', 'max_iter' and 'zoom'.")
+	}
+
+	// Oversized images are rejected before allocating; iterations are only clamped
+	maxWidth := envInt("MANDELBROT_MAX_WIDTH", 4096)
+	maxHeight := envInt("MANDELBROT_MAX_HEIGHT", 4096)
+	if f.width > maxWidth || f.height > maxHeight {
+		return nil, "", "", fmt.Errorf("Image too large: maximum size is %dx%d.", maxWidth, maxHeight)
+	}
+	f.maxIter = min(f.maxIter, envInt("MANDELBROT_MAX_ITER", 5000))
+
+	palette = params["palette"]
+	if palette == "" {
+		palette = "grayscale"
+	}
+	colorize, ok := palettes[palette]
+	if !ok {
+		return nil, "", "", errors.New("Unknown palette. Supported: grayscale, fire, ocean, rainbow")
+	}
+	f.colorize = colorize
+
+	format = params["fmt"]
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "jpeg" && format != "gif" {
+		return nil, "", "", errors.New("Unknown format. Supported: png, jpeg, gif")
+	}
+	return f, palette, format, nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	params := payload.Params
+	f, palette, format, err := parseFractal(params)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	meta := f.metadata(palette)
+
+	// Bands let clients start assembling a large image before it is finished.
+	// They need a route that streams and turns the header block into headers.
+	if band := intParam(params, "band", 0); band > 0 {
+		if os.Getenv("MANDELBROT_HEADERS") != "true" {
+			fmt.Println("Banded output is not enabled on this route.")
+			return
+		}
+		if err := f.renderBands(bufio.NewWriter(os.Stdout), band, format, params, meta); err != nil {
+			fmt.Println("Error encoding image:", err)
+		}
+		return
+	}
+
+	img := f.render(renderWorkers())
+
+	out := bufio.NewWriter(os.Stdout)
+	if os.Getenv("MANDELBROT_HEADERS") == "true" {
+		// Only routes with "headers": true split the block from the body;
+		// elsewhere the server sniffs the content type from the image
+		meta.Set("Content-Type", "image/"+format)
+		writeHeaders(out, meta)
+	}
+	if err := encode(out, img, format, params); err != nil {
+		fmt.Println("Error encoding image:", err)
+		return
+	}
+	out.Flush()
+}
@@ -0,0 +1,327 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test mandelbrot.go mandelbrot_test.go
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testFractal returns a small Mandelbrot render with the instrument's defaults.
+func testFractal(width, height int) *fractal {
+	return &fractal{
+		width: width, height: height, maxIter: 100,
+		zoom: 1, centerX: -0.5, smooth: true,
+		jx: -0.8, jy: 0.156,
+		colorize: mapColor,
+	}
+}
+
+func TestRenderParallel(t *testing.T) {
+	for _, f := range []*fractal{testFractal(97, 61), {width: 50, height: 33, maxIter: 200, zoom: 2, julia: true, jx: -0.8, jy: 0.156, colorize: rainbowColor}} {
+		want := f.render(1)
+		// Worker counts that don't divide the height leave a short last band
+		for _, workers := range []int{2, 4, 7, f.height, f.height + 5} {
+			if got := f.render(workers); !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("%dx%d julia=%v: %d workers differ from sequential rendering", f.width, f.height, f.julia, workers)
+			}
+		}
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	f := testFractal(640, 480)
+	b.Run("sequential", func(b *testing.B) {
+		for range b.N {
+			f.render(1)
+		}
+	})
+	// Use at least two workers so the parallel path runs even on one CPU
+	workers := max(runtime.NumCPU(), 2)
+	b.Run(fmt.Sprintf("parallel-%d", workers), func(b *testing.B) {
+		for range b.N {
+			f.render(workers)
+		}
+	})
+}
+
+func TestPalettes(t *testing.T) {
+	// c = 0.5+0.5i lies outside the set and escapes after a few iterations
+	iter := escape(0, 0, 0.5, 0.5, 100, true)
+	if iter <= 0 || iter >= 100 {
+		t.Fatalf("escape(0.5+0.5i) = %v, want a point outside the set", iter)
+	}
+	seen := map[color.RGBA]string{}
+	for name, colorize := range palettes {
+		c := colorize(iter, 100)
+		if again := colorize(escape(0, 0, 0.5, 0.5, 100, true), 100); again != c {
+			t.Errorf("%s: %v, then %v for the same point", name, c, again)
+		}
+		if other, dup := seen[c]; dup {
+			t.Errorf("%s and %s both color the point %v", name, other, c)
+		}
+		seen[c] = name
+	}
+
+	// Exact values at half the iterations, and for points inside the set
+	tests := []struct {
+		palette     string
+		half, still color.RGBA
+	}{
+		{"grayscale", color.RGBA{127, 127, 191, 255}, color.RGBA{0, 0, 0, 255}},
+		{"fire", color.RGBA{255, 127, 0, 255}, color.RGBA{0, 0, 0, 255}},
+		{"ocean", color.RGBA{32, 180, 191, 255}, color.RGBA{0, 0, 32, 255}},
+		{"rainbow", color.RGBA{0, 255, 255, 255}, color.RGBA{0, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		colorize := palettes[tt.palette]
+		if got := colorize(50, 100); got != tt.half {
+			t.Errorf("%s at 50/100 = %v, want %v", tt.palette, got, tt.half)
+		}
+		if got := colorize(100, 100); got != tt.still {
+			t.Errorf("%s inside the set = %v, want %v", tt.palette, got, tt.still)
+		}
+	}
+}
+
+func TestJulia(t *testing.T) {
+	f, _, _, err := parseFractal(map[string]string{"type": "julia", "width": "64", "height": "48"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.jx != -0.8 || f.jy != 0.156 || f.centerX != 0 || f.centerY != 0 {
+		t.Errorf("default julia c = %v%+vi centered at %v%+vi, want -0.8+0.156i at the origin", f.jx, f.jy, f.centerX, f.centerY)
+	}
+	img := f.render(1)
+	black := color.RGBA{0, 0, 0, 255}
+	colored := 0
+	for y := range f.height {
+		for x := range f.width {
+			if img.RGBAAt(x, y) != black {
+				colored++
+			}
+		}
+	}
+	if colored == 0 {
+		t.Error("default julia set rendered all black")
+	}
+
+	mandelbrot, _, _, _ := parseFractal(map[string]string{"width": "64", "height": "48"})
+	if bytes.Equal(img.Pix, mandelbrot.render(1).Pix) {
+		t.Error("julia rendered the same image as mandelbrot")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	f, _, _, err := parseFractal(map[string]string{"max_iter": "100000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.maxIter != 5000 {
+		t.Errorf("max_iter clamped to %d, want the default limit 5000", f.maxIter)
+	}
+
+	t.Setenv("MANDELBROT_MAX_ITER", "300")
+	t.Setenv("MANDELBROT_MAX_WIDTH", "200")
+	t.Setenv("MANDELBROT_MAX_HEIGHT", "100")
+	if f, _, _, err = parseFractal(map[string]string{"max_iter": "1000", "width": "200", "height": "100"}); err != nil {
+		t.Fatalf("image at the limits: %v", err)
+	}
+	if f.maxIter != 300 {
+		t.Errorf("max_iter clamped to %d, want 300", f.maxIter)
+	}
+	if f, _, _, _ = parseFractal(map[string]string{"max_iter": "50", "width": "10", "height": "10"}); f.maxIter != 50 {
+		t.Errorf("max_iter below the limit changed to %d", f.maxIter)
+	}
+
+	for _, params := range []map[string]string{
+		{"width": "201", "height": "100"},
+		{"width": "200", "height": "101"},
+		{"width": "100000", "height": "100000"},
+	} {
+		if _, _, _, err := parseFractal(params); err == nil || !strings.Contains(err.Error(), "maximum size is 200x100") {
+			t.Errorf("%v: got %v, want the size rejected", params, err)
+		}
+	}
+	for _, params := range []map[string]string{
+		{"width": "0"},
+		{"height": "-1"},
+		{"max_iter": "0"},
+		{"zoom": "-2"},
+	} {
+		if _, _, _, err := parseFractal(params); err == nil {
+			t.Errorf("%v accepted", params)
+		}
+	}
+}
+
+func TestFormats(t *testing.T) {
+	f := testFractal(40, 30)
+	img := f.render(1)
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		var buf bytes.Buffer
+		if err := encode(&buf, img, format, map[string]string{"quality": "80"}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		decoded, got, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: decoding: %v", format, err)
+		}
+		if got != format {
+			t.Errorf("fmt=%s produced %s", format, got)
+		}
+		if size := decoded.Bounds().Size(); size != image.Pt(40, 30) {
+			t.Errorf("%s: decoded size %v, want 40x30", format, size)
+		}
+	}
+	// PNG is lossless
+	var buf bytes.Buffer
+	encode(&buf, img, "png", nil)
+	decoded, _ := png.Decode(&buf)
+	for y := range f.height {
+		for x := range f.width {
+			if color.RGBAModel.Convert(decoded.At(x, y)) != img.RGBAAt(x, y) {
+				t.Fatalf("png pixel (%d, %d) changed", x, y)
+			}
+		}
+	}
+}
+
+func TestRenderBands(t *testing.T) {
+	f := testFractal(45, 37)
+	var buf bytes.Buffer
+	if err := f.renderBands(bufio.NewWriter(&buf), 10, "png", nil, f.metadata("grayscale")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The header block names the boundary, followed by the multipart body
+	body := bufio.NewReader(&buf)
+	block, err := textproto.NewReader(body).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("reading the header block: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(block.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" || params["boundary"] == "" {
+		t.Fatalf("Content-Type %q, want multipart/mixed with a boundary", block.Get("Content-Type"))
+	}
+	if block.Get("X-Mandelbrot-Width") != "45" {
+		t.Errorf("header block lacks the metadata: %v", block)
+	}
+	var other bytes.Buffer
+	f.renderBands(bufio.NewWriter(&other), 10, "png", nil, nil)
+	if bytes.Contains(other.Bytes(), []byte(params["boundary"])) {
+		t.Error("two responses share a boundary")
+	}
+
+	assembled := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	mr := multipart.NewReader(body, params["boundary"])
+	tiles := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, _ := strconv.Atoi(part.Header.Get("X-Tile-Y"))
+		height, _ := strconv.Atoi(part.Header.Get("X-Tile-Height"))
+		if part.Header.Get("X-Image-Width") != "45" || part.Header.Get("X-Image-Height") != "37" {
+			t.Errorf("tile at %d: image size %sx%s", y, part.Header.Get("X-Image-Width"), part.Header.Get("X-Image-Height"))
+		}
+		tile, err := png.Decode(part)
+		if err != nil {
+			t.Fatalf("tile at %d: %v", y, err)
+		}
+		if size := tile.Bounds().Size(); size != image.Pt(f.width, height) {
+			t.Errorf("tile at %d is %v, want %dx%d", y, size, f.width, height)
+		}
+		draw.Draw(assembled, image.Rect(0, y, f.width, y+height), tile, tile.Bounds().Min, draw.Src)
+		tiles++
+	}
+	if tiles != 4 {
+		t.Errorf("%d tiles, want 4 bands of up to 10 rows", tiles)
+	}
+	if !bytes.Equal(assembled.Pix, f.render(1).Pix) {
+		t.Error("assembled tiles differ from the full image")
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	// Walk outward along Im(c) = 0.8, where the escape count crosses from 4 to 5 iterations
+	const steps, maxIter = 200, 200
+	at := func(i int, smooth bool) float64 {
+		return escape(0, 0, 0.3+float64(i)*1e-3, 0.8, maxIter, smooth)
+	}
+	smoothJump, bandJump := 0.0, 0.0
+	colorJump := 0
+	for i := 1; i <= steps; i++ {
+		smoothJump = math.Max(smoothJump, math.Abs(at(i, true)-at(i-1, true)))
+		bandJump = math.Max(bandJump, math.Abs(at(i, false)-at(i-1, false)))
+		a, b := fireColor(at(i, true), maxIter), fireColor(at(i-1, true), maxIter)
+		for _, d := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B)} {
+			colorJump = max(colorJump, d, -d)
+		}
+	}
+	if bandJump < 1 {
+		t.Fatalf("banded counts never change along the line (max step %v)", bandJump)
+	}
+	if smoothJump > 0.05 {
+		t.Errorf("smooth counts jump by %v between adjacent points, want gradual change", smoothJump)
+	}
+	if colorJump > 1 {
+		t.Errorf("smooth colors of adjacent points differ by up to %d per channel", colorJump)
+	}
+	if v := at(0, true); v == math.Trunc(v) {
+		t.Errorf("smooth count %v is a whole iteration", v)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	t.Setenv("MANDELBROT_MAX_ITER", "300")
+	tests := []struct {
+		params map[string]string
+		want   map[string]string
+	}{
+		{map[string]string{}, map[string]string{
+			"X-Mandelbrot-Type": "mandelbrot", "X-Mandelbrot-Width": "640", "X-Mandelbrot-Height": "480",
+			"X-Mandelbrot-Iter": "100", "X-Mandelbrot-Zoom": "1", "X-Mandelbrot-X": "-0.5", "X-Mandelbrot-Y": "0",
+			"X-Mandelbrot-Palette": "grayscale", "X-Mandelbrot-Smooth": "true", "X-Mandelbrot-Jx": "",
+		}},
+		{map[string]string{"max_iter": "9999", "zoom": "2.5", "palette": "fire", "smooth": "false", "x": "bogus"}, map[string]string{
+			"X-Mandelbrot-Iter": "300", "X-Mandelbrot-Zoom": "2.5", "X-Mandelbrot-X": "-0.5",
+			"X-Mandelbrot-Palette": "fire", "X-Mandelbrot-Smooth": "false",
+		}},
+		{map[string]string{"type": "julia", "jy": "0.3"}, map[string]string{
+			"X-Mandelbrot-Type": "julia", "X-Mandelbrot-X": "0", "X-Mandelbrot-Jx": "-0.8", "X-Mandelbrot-Jy": "0.3",
+		}},
+	}
+	for _, tt := range tests {
+		f, palette, _, err := parseFractal(tt.params)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.params, err)
+		}
+		meta := f.metadata(palette)
+		for key, want := range tt.want {
+			if got := meta.Get(key); got != want {
+				t.Errorf("%v: %s = %q, want %q", tt.params, key, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// wikiLinkPattern matches a [[Page]] or [[Page|Label]] link at the start of the input.
+var wikiLinkPattern = regexp.MustCompile(`^\[\[([^\[\]]+)\]\]`)
+
+// slugify produces a lowercase, ASCII-only, hyphen-separated slug.
+func slugify(text string) string {
+	// Decompose accented letters so the base letter survives the ASCII filter
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if r < utf8.RuneSelf || !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	parts := strings.FieldsFunc(strings.ToLower(b.String()), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9')
+	})
+	return strings.Join(parts, "-")
+}
+
+// wikiLinkParser is a goldmark inline parser for [[Page]] and [[Page|Label]]
+// links. They point at base followed by the slug of Page and show the label
+// as written; a blank label falls back to the page name. Being part of the
+// Markdown parser, it leaves code spans and code blocks alone.
+type wikiLinkParser struct {
+	base string
+}
+
+func (p wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	m := wikiLinkPattern.FindSubmatchIndex(line)
+	if m == nil {
+		return nil
+	}
+	page, label, _ := strings.Cut(string(line[m[2]:m[3]]), "|")
+	page, label = strings.TrimSpace(page), strings.TrimSpace(label)
+	slug := slugify(page)
+	if slug == "" {
+		// Leave it to the regular link parser
+		return nil
+	}
+	if label == "" {
+		label = page
+	}
+	block.Advance(m[1])
+	link := ast.NewLink()
+	link.Destination = []byte(p.base + slug)
+	link.AppendChild(link, ast.NewString([]byte(label)))
+	return link
+}
+
+// render converts GitHub-flavoured Markdown with wikilinks to HTML. Raw HTML
+// in the input is not passed through.
+func render(source, wikiBase string) (string, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		// Ahead of the link parser (200), which would otherwise claim the brackets
+		goldmark.WithParserOptions(parser.WithInlineParsers(util.Prioritized(wikiLinkParser{wikiBase}, 199))),
+	)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	wikiBase := payload.Params["wiki_base"]
+	if wikiBase == "" {
+		wikiBase = "/wiki?page="
+	}
+	body, err := render(payload.Params["text"], wikiBase)
+	if err != nil {
+		fmt.Println("Error rendering Markdown:", err)
+		return
+	}
+
+	if payload.Params["fragment"] == "true" {
+		fmt.Print(body)
+		return
+	}
+	title := payload.Params["title"]
+	if title == "" {
+		title = "Markdown"
+	}
+	fmt.Printf("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n%s</body>\n</html>\n",
+		html.EscapeString(title), body)
+}
@@ -0,0 +1,70 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test markdown.go markdown_test.go
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct{ name, in, want string }{
+		{"heading", "# Title\n", "<h1>Title</h1>\n"},
+		{"emphasis", "*a* and **b**\n", "<p><em>a</em> and <strong>b</strong></p>\n"},
+		{"list", "- one\n- *two*\n", "<ul>\n<li>one</li>\n<li><em>two</em></li>\n</ul>\n"},
+		{"ordered list", "1. one\n2. two\n", "<ol>\n<li>one</li>\n<li>two</li>\n</ol>\n"},
+		{"code fence", "```go\nx := 1 < 2\n```\n", "<pre><code class=\"language-go\">x := 1 &lt; 2\n</code></pre>\n"},
+		{"strikethrough", "~~old~~\n", "<p><del>old</del></p>\n"},
+		{"raw HTML", "<script>alert(1)</script>\n", "<!-- raw HTML omitted -->\n"},
+	}
+	for _, tt := range tests {
+		got, err := render(tt.in, "/wiki?page=")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: render(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderWikiLinks(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"[[Main Page]]", `<p><a href="/wiki?page=main-page">Main Page</a></p>`},
+		{"[[Main Page|the start]]", `<p><a href="/wiki?page=main-page">the start</a></p>`},
+		{"[[Main Page|  ]]", `<p><a href="/wiki?page=main-page">Main Page</a></p>`},
+		{"[[ Über uns | Über *uns* ]]", `<p><a href="/wiki?page=uber-uns">Über *uns*</a></p>`},
+		{"[[Page|<b>]]", `<p><a href="/wiki?page=page">&lt;b&gt;</a></p>`},
+		{"see [[A]] and [[B|b]]", `<p>see <a href="/wiki?page=a">A</a> and <a href="/wiki?page=b">b</a></p>`},
+		{"- [[A]]\n- *[[B]]*", "<ul>\n<li><a href=\"/wiki?page=a\">A</a></li>\n<li><em><a href=\"/wiki?page=b\">B</a></em></li>\n</ul>"},
+		{"[[A]] [b](/c)", `<p><a href="/wiki?page=a">A</a> <a href="/c">b</a></p>`},
+		// Malformed links stay text
+		{"[[Page|a [b] c]]", "<p>[[Page|a [b] c]]</p>"},
+		{"[[Page]", "<p>[[Page]</p>"},
+		{"[[ ]]", "<p>[[ ]]</p>"},
+		{"[[|Label]]", "<p>[[|Label]]</p>"},
+		{"[[!!!]]", "<p>[[!!!]]</p>"},
+		// Code is never rewritten
+		{"`[[code]]`", "<p><code>[[code]]</code></p>"},
+		{"```\n[[code]]\n```", "<pre><code>[[code]]\n</code></pre>"},
+		{"    [[code]]", "<pre><code>[[code]]\n</code></pre>"},
+	}
+	for _, tt := range tests {
+		got, err := render(tt.in, "/wiki?page=")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want+"\n" {
+			t.Errorf("render(%q) = %q, want %q", tt.in, got, tt.want+"\n")
+		}
+	}
+}
+
+func TestRenderWikiBase(t *testing.T) {
+	got, err := render("[[Main Page]]", "/wiki/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<p><a href=\"/wiki/main-page\">Main Page</a></p>\n"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+}
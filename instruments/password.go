@@ -0,0 +1,181 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// Character classes available for password generation.
+const (
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{};:,.?/"
+)
+
+// ambiguousChars are easily confused when read or typed by hand.
+const ambiguousChars = "Il1O0o|`'\""
+
+// effWordList is the EFF large wordlist, https://www.eff.org/dice, with one
+// "<dice roll>\t<word>" entry per line. Its 7776 words give about 12.9 bits
+// of entropy per passphrase word.
+//
+//go:embed eff_large_wordlist.txt
+var effWordList string
+
+// wordList holds the words of effWordList.
+var wordList = parseWordList(effWordList)
+
+// parseWordList extracts the words from a diceware list, dropping the rolls.
+func parseWordList(list string) []string {
+	lines := strings.Split(strings.TrimSpace(list), "\n")
+	words := make([]string, 0, len(lines))
+	for _, line := range lines {
+		_, word, _ := strings.Cut(line, "\t")
+		words = append(words, strings.TrimSpace(word))
+	}
+	return words
+}
+
+// newRNG returns a ChaCha8 generator keyed from crypto/rand. If the client
+// explicitly passes a 'seed' param, the generator is derived from it instead,
+// so the same seed always yields the same output. The server's payload seed
+// is never used, as it is echoed in the X-WASIO-Seed response header.
+func newRNG(params map[string]string) (*rand.Rand, error) {
+	var key [32]byte
+	if s, ok := params["seed"]; ok {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'seed' must be an integer")
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(seed))
+		key = sha256.Sum256(buf[:])
+	} else if _, err := cryptorand.Read(key[:]); err != nil {
+		return nil, fmt.Errorf("reading random bytes: %v", err)
+	}
+	return rand.New(rand.NewChaCha8(key)), nil
+}
+
+// intParam returns the named parameter as an int, or def if absent or invalid.
+func intParam(params map[string]string, name string, def int) int {
+	if v, err := strconv.Atoi(params[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// enabled reports whether a toggle is on; toggles default to on.
+func enabled(params map[string]string, name string) bool {
+	return params[name] != "false"
+}
+
+// charClasses returns the character classes enabled by params, without the
+// ambiguous characters if 'exclude-ambiguous' is set.
+func charClasses(params map[string]string) []string {
+	var classes []string
+	for _, c := range []struct{ name, chars string }{
+		{"upper", upperChars}, {"lower", lowerChars}, {"digits", digitChars}, {"symbols", symbolChars},
+	} {
+		if !enabled(params, c.name) {
+			continue
+		}
+		chars := c.chars
+		if params["exclude-ambiguous"] == "true" {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+	return classes
+}
+
+// password generates a password of length characters drawn from the enabled
+// classes, containing at least one character of each.
+func password(rng *rand.Rand, length int, classes []string) (string, error) {
+	if len(classes) == 0 {
+		return "", fmt.Errorf("at least one character class must be enabled")
+	}
+	if length < len(classes) {
+		return "", fmt.Errorf("length must be at least %d for the enabled classes", len(classes))
+	}
+
+	chars := make([]byte, 0, length)
+	for _, class := range classes {
+		chars = append(chars, class[rng.IntN(len(class))])
+	}
+	all := strings.Join(classes, "")
+	for len(chars) < length {
+		chars = append(chars, all[rng.IntN(len(all))])
+	}
+	rng.Shuffle(len(chars), func(i, j int) { chars[i], chars[j] = chars[j], chars[i] })
+	return string(chars), nil
+}
+
+// passphrase joins count random words from the embedded word list.
+func passphrase(rng *rand.Rand, count int, sep string) string {
+	words := make([]string, count)
+	for i := range words {
+		words[i] = wordList[rng.IntN(len(wordList))]
+	}
+	return strings.Join(words, sep)
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	params := payload.Params
+	rng, err := newRNG(params)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if params["mode"] == "words" {
+		count := intParam(params, "words", 5)
+		if count < 1 || count > 32 {
+			fmt.Println("Please provide a 'words' count between 1 and 32.")
+			return
+		}
+		sep, ok := params["separator"]
+		if !ok {
+			sep = "-"
+		}
+		fmt.Println(passphrase(rng, count, sep))
+		return
+	}
+
+	length := intParam(params, "length", 16)
+	if length < 1 || length > 256 {
+		fmt.Println("Please provide a 'length' between 1 and 256.")
+		return
+	}
+
+	result, err := password(rng, length, charClasses(params))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(result)
+}
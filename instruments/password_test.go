@@ -0,0 +1,98 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test password.go password_test.go
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	rng, err := newRNG(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes := charClasses(map[string]string{})
+	for _, length := range []int{4, 5, 16, 256} {
+		for range 50 {
+			pw, err := password(rng, length, classes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pw) != length {
+				t.Fatalf("length %d gave %q", length, pw)
+			}
+			for _, class := range classes {
+				if !strings.ContainsAny(pw, class) {
+					t.Fatalf("%q has no character of %q", pw, class)
+				}
+			}
+			if strings.Trim(pw, strings.Join(classes, "")) != "" {
+				t.Fatalf("%q has characters outside the classes", pw)
+			}
+		}
+	}
+
+	if _, err := password(rng, 3, classes); err == nil {
+		t.Error("length shorter than the class count accepted")
+	}
+	if _, err := password(rng, 8, nil); err == nil {
+		t.Error("password without classes accepted")
+	}
+}
+
+func TestCharClasses(t *testing.T) {
+	if got := charClasses(map[string]string{"upper": "false", "symbols": "false"}); len(got) != 2 || got[0] != lowerChars || got[1] != digitChars {
+		t.Errorf("lower and digits only = %q", got)
+	}
+	if got := charClasses(map[string]string{"upper": "false", "lower": "false", "digits": "false", "symbols": "false"}); len(got) != 0 {
+		t.Errorf("all disabled = %q", got)
+	}
+
+	classes := charClasses(map[string]string{"exclude-ambiguous": "true"})
+	all := strings.Join(classes, "")
+	if strings.ContainsAny(all, ambiguousChars) {
+		t.Errorf("ambiguous characters left in %q", all)
+	}
+	for _, c := range "ABZabz29!?" {
+		if !strings.ContainsRune(all, c) {
+			t.Errorf("%c excluded", c)
+		}
+	}
+	rng, _ := newRNG(map[string]string{})
+	for range 100 {
+		if pw, _ := password(rng, 64, classes); strings.ContainsAny(pw, ambiguousChars) {
+			t.Fatalf("%q contains an ambiguous character", pw)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	generate := func(params map[string]string) (string, string) {
+		t.Helper()
+		rng, err := newRNG(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pw, _ := password(rng, 20, charClasses(params))
+		return pw, passphrase(rng, 4, " ")
+	}
+	pw, phrase := generate(map[string]string{"seed": "42"})
+	if pw2, phrase2 := generate(map[string]string{"seed": "42"}); pw2 != pw || phrase2 != phrase {
+		t.Errorf("seed 42 gave %q %q, then %q %q", pw, phrase, pw2, phrase2)
+	}
+	if pw2, _ := generate(map[string]string{"seed": "43"}); pw2 == pw {
+		t.Errorf("seeds 42 and 43 both gave %q", pw)
+	}
+	if pw2, _ := generate(map[string]string{}); pw2 == pw {
+		t.Errorf("unseeded run repeated the seeded password %q", pw)
+	}
+	if len(strings.Fields(phrase)) != 4 {
+		t.Errorf("passphrase %q doesn't have 4 words", phrase)
+	}
+	if _, err := newRNG(map[string]string{"seed": "abc"}); err == nil {
+		t.Error("non-integer seed accepted")
+	}
+}
@@ -3,12 +3,72 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
+	"strconv"
+	"strings"
 )
 
+// maxCount bounds how many numbers a single request may generate.
+const maxCount = 1000
+
 type Payload struct {
-	Seed int64 `json:"seed"`
+	Params map[string]string `json:"params"`
+	Seed   int64             `json:"seed"`
+}
+
+// int64Param returns the named parameter as an int64, or def if absent.
+func int64Param(params map[string]string, name string, def int64) (int64, error) {
+	v, ok := params[name]
+	if !ok || v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' must be an integer", name)
+	}
+	return n, nil
+}
+
+// parseRange reads and validates the min, max and count parameters.
+func parseRange(params map[string]string) (lo, hi, count int64, err error) {
+	if lo, err = int64Param(params, "min", 0); err != nil {
+		return
+	}
+	if hi, err = int64Param(params, "max", 99); err != nil {
+		return
+	}
+	if count, err = int64Param(params, "count", 1); err != nil {
+		return
+	}
+	if lo > hi {
+		err = fmt.Errorf("'min' must not be greater than 'max'")
+	} else if count < 1 || count > maxCount {
+		err = fmt.Errorf("'count' must be between 1 and %d", maxCount)
+	}
+	return
+}
+
+// generate draws count numbers from [lo, hi] using rng.
+func generate(rng *rand.Rand, lo, hi, count int64) []string {
+	span := uint64(hi-lo) + 1
+	numbers := make([]string, count)
+	for i := range numbers {
+		var n int64
+		if span > math.MaxInt64 || span == 0 {
+			// The range exceeds int63, so draw raw bits and reject values outside it
+			for {
+				if n = int64(rng.Uint64()); n >= lo && n <= hi {
+					break
+				}
+			}
+		} else {
+			n = lo + rng.Int63n(int64(span))
+		}
+		numbers[i] = strconv.FormatInt(n, 10)
+	}
+	return numbers
 }
 
 func main() {
@@ -20,9 +80,17 @@ func main() {
 		return
 	}
 
-	// Set random seed and generate a random number
-	rand.Seed(payload.Seed)
-	randomNumber := rand.Intn(100) // Generates a number between 0 and 99
+	lo, hi, count, err := parseRange(payload.Params)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 
-	fmt.Printf("Generated Random Number: %d\n", randomNumber)
+	// A dedicated source keeps the sequence reproducible for a given seed
+	numbers := generate(rand.New(rand.NewSource(payload.Seed)), lo, hi, count)
+	if count == 1 {
+		fmt.Printf("Generated Random Number: %s\n", numbers[0])
+		return
+	}
+	fmt.Printf("Generated Random Numbers: %s\n", strings.Join(numbers, ", "))
 }
@@ -0,0 +1,78 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test random_number.go random_number_test.go
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSeeded(t *testing.T) {
+	draw := func(seed int64) string {
+		return fmt.Sprint(generate(rand.New(rand.NewSource(seed)), 1, 6, 20))
+	}
+	first := draw(42)
+	if again := draw(42); again != first {
+		t.Errorf("seed 42 gave %s, then %s", first, again)
+	}
+	if other := draw(43); other == first {
+		t.Errorf("seeds 42 and 43 both gave %s", first)
+	}
+	// The sequence is part of the contract: clients replay runs by seed
+	if want := "[2 2 1 6 6 4 4 1 3 3 1 6 1 2 3 3 6 5 4 6]"; first != want {
+		t.Errorf("seed 42 gave %s, want %s", first, want)
+	}
+}
+
+func TestGenerateRange(t *testing.T) {
+	tests := []struct {
+		lo, hi int64
+	}{
+		{0, 0},
+		{-5, 5},
+		{math.MaxInt64 - 1, math.MaxInt64},
+		// Spans wider than int63 use rejection sampling
+		{-1, math.MaxInt64},
+		{math.MinInt64, math.MaxInt64},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		for _, s := range generate(rng, tt.lo, tt.hi, 200) {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < tt.lo || n > tt.hi {
+				t.Errorf("[%d, %d] produced %s", tt.lo, tt.hi, s)
+			}
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	lo, hi, count, err := parseRange(map[string]string{})
+	if err != nil || lo != 0 || hi != 99 || count != 1 {
+		t.Errorf("defaults = %d, %d, %d, %v; want 0, 99, 1", lo, hi, count, err)
+	}
+	if _, _, _, err := parseRange(map[string]string{"min": "7", "max": "7", "count": "1000"}); err != nil {
+		t.Errorf("min=max and the largest count rejected: %v", err)
+	}
+	for _, tt := range []struct {
+		params map[string]string
+		want   string
+	}{
+		{map[string]string{"min": "10", "max": "9"}, "'min' must not be greater than 'max'"},
+		{map[string]string{"count": "0"}, "'count' must be between 1 and 1000"},
+		{map[string]string{"count": "1001"}, "'count' must be between 1 and 1000"},
+		{map[string]string{"count": "-3"}, "'count' must be between 1 and 1000"},
+		{map[string]string{"max": "lots"}, "'max' must be an integer"},
+		{map[string]string{"min": "1.5"}, "'min' must be an integer"},
+	} {
+		if _, _, _, err := parseRange(tt.params); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want %q", tt.params, err, tt.want)
+		}
+	}
+}
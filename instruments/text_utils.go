@@ -0,0 +1,699 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// maxInputLen caps the input size for the more expensive operations.
+const maxInputLen = 64 * 1024
+
+// maxDiffLines caps the number of differing lines diff compares, as the LCS
+// table grows with the product of both sides.
+const maxDiffLines = 1000
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDistanceLen caps the rune length of each string compared by distance.
+const maxDistanceLen = 10000
+
+// supportedOps lists the operations understood by this instrument.
+var supportedOps = []string{
+	"freq", "regexmatch", "regexreplace",
+	"camel", "pascal", "snake", "kebab", "screaming", "slug",
+	"distance", "similarity", "rot13", "caesar",
+	"sort", "dedup", "count", "stats", "diff", "links", "emails",
+}
+
+// reportOps are the operations whose output is a human-readable report, so
+// the statistics footer can follow it. Other operations return text or values
+// meant to be consumed as-is.
+var reportOps = map[string]bool{"freq": true, "regexmatch": true, "count": true}
+
+// urlPattern matches http(s) URLs up to the next whitespace or delimiter;
+// trailing punctuation is trimmed separately by cleanURL.
+var urlPattern = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+
+// emailPattern matches addresses with a dotted domain and a letter-only TLD.
+var emailPattern = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*\.[A-Za-z]{2,}\b`)
+
+// abbreviations lists common abbreviations whose trailing period doesn't end a sentence.
+var abbreviations = map[string]bool{
+	"mr.": true, "mrs.": true, "ms.": true, "dr.": true, "prof.": true, "sr.": true,
+	"jr.": true, "st.": true, "vs.": true, "etc.": true, "approx.": true, "no.": true,
+	"fig.": true, "inc.": true, "ltd.": true, "co.": true, "jan.": true, "feb.": true,
+	"mar.": true, "apr.": true, "jun.": true, "jul.": true, "aug.": true, "sep.": true,
+	"sept.": true, "oct.": true, "nov.": true, "dec.": true,
+}
+
+// stopwords lists common English words excluded from frequency counts on request.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "he": true, "her": true, "his": true, "i": true, "if": true,
+	"in": true, "is": true, "it": true, "its": true, "of": true, "on": true,
+	"or": true, "she": true, "so": true, "that": true, "the": true, "their": true,
+	"them": true, "there": true, "they": true, "this": true, "to": true, "was": true,
+	"we": true, "were": true, "which": true, "with": true, "you": true,
+}
+
+// wordCount pairs a word with the number of times it occurs.
+type wordCount struct {
+	Word  string
+	Count int
+}
+
+// normalizeWords splits text into lowercase words with punctuation stripped.
+func normalizeWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	words := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.Trim(f, "'"); f != "" {
+			words = append(words, f)
+		}
+	}
+	return words
+}
+
+// wordFrequencies returns the n most common words, ties sorted alphabetically.
+func wordFrequencies(text string, n int, skipStopwords bool) []wordCount {
+	counts := map[string]int{}
+	for _, w := range normalizeWords(text) {
+		if skipStopwords && stopwords[w] {
+			continue
+		}
+		counts[w]++
+	}
+
+	result := make([]wordCount, 0, len(counts))
+	for w, c := range counts {
+		result = append(result, wordCount{Word: w, Count: c})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Word < result[j].Word
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// compilePattern validates input size and compiles an RE2 pattern.
+func compilePattern(pattern, text string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("please provide a 'pattern' parameter")
+	}
+	if len(text) > maxInputLen {
+		return nil, fmt.Errorf("input exceeds %d bytes", maxInputLen)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	return re, nil
+}
+
+// describeMatches reports the matches of re in text with their byte offsets
+// and the capture groups that participated in each.
+func describeMatches(re *regexp.Regexp, text string) string {
+	var b strings.Builder
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	fmt.Fprintf(&b, "%d matches\n", len(matches))
+	for _, m := range matches {
+		fmt.Fprintf(&b, "[%d-%d] %q", m[0], m[1], text[m[0]:m[1]])
+		for g := 1; g < len(m)/2; g++ {
+			if m[2*g] >= 0 {
+				fmt.Fprintf(&b, " $%d=%q", g, text[m[2*g]:m[2*g+1]])
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// splitWords breaks identifiers and phrases into words, splitting on separators
+// and case changes. Acronyms stay together ("HTTPServer" -> "HTTP", "Server")
+// and digit runs stay attached to the word they follow.
+func splitWords(text string) []string {
+	var words []string
+	runes := []rune(text)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		lowerToUpper := unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev))
+		acronymEnd := unicode.IsUpper(r) && unicode.IsUpper(prev) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// capitalize uppercases the first rune of a word and lowercases the rest.
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + strings.ToLower(word[size:])
+}
+
+// convertCase joins the words of text in the requested style.
+func convertCase(text, style string) (string, error) {
+	words := splitWords(text)
+	switch style {
+	case "camel", "pascal":
+		var b strings.Builder
+		for i, w := range words {
+			if i == 0 && style == "camel" {
+				b.WriteString(strings.ToLower(w))
+			} else {
+				b.WriteString(capitalize(w))
+			}
+		}
+		return b.String(), nil
+	case "snake":
+		return strings.ToLower(strings.Join(words, "_")), nil
+	case "kebab":
+		return strings.ToLower(strings.Join(words, "-")), nil
+	case "screaming":
+		return strings.ToUpper(strings.Join(words, "_")), nil
+	}
+	return "", fmt.Errorf("unknown case style '%s'", style)
+}
+
+// slugify produces a lowercase, ASCII-only, hyphen-separated slug.
+func slugify(text string) string {
+	// Decompose accented letters so the base letter survives the ASCII filter
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if r < utf8.RuneSelf || !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	parts := strings.FieldsFunc(strings.ToLower(b.String()), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9')
+	})
+	return strings.Join(parts, "-")
+}
+
+// levenshtein computes the edit distance between a and b using two DP rows.
+func levenshtein(a, b []rune) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// similarity scales the edit distance to [0, 1] by the longer string's
+// length, so 1 means equal. Two empty strings are equal.
+func similarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// distanceInputs validates and converts the two strings compared by distance ops.
+func distanceInputs(text, other string) ([]rune, []rune, error) {
+	a, b := []rune(text), []rune(other)
+	if len(a) > maxDistanceLen || len(b) > maxDistanceLen {
+		return nil, nil, fmt.Errorf("inputs must not exceed %d characters", maxDistanceLen)
+	}
+	return a, b, nil
+}
+
+// caesar shifts ASCII letters by shift positions, preserving case and other runes.
+func caesar(text string, shift int) string {
+	shift = ((shift % 26) + 26) % 26
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+rune(shift))%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+rune(shift))%26
+		}
+		return r
+	}, text)
+}
+
+// splitLines splits text into lines, ignoring a single trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// lineKey returns the key lines are compared by, folding case if requested.
+func lineKey(line string, ignoreCase bool) string {
+	if ignoreCase {
+		return strings.ToLower(line)
+	}
+	return line
+}
+
+// sortLines sorts lines alphabetically, or by their numeric value with lines
+// that aren't numbers placed last. The sort is stable, so equal lines keep
+// their input order, in reverse mode too.
+func sortLines(lines []string, reverse, numeric, ignoreCase bool) {
+	compare := func(a, b string) int {
+		if numeric {
+			x, errX := strconv.ParseFloat(strings.TrimSpace(a), 64)
+			y, errY := strconv.ParseFloat(strings.TrimSpace(b), 64)
+			switch {
+			case errX != nil && errY != nil:
+				// Fall through to the alphabetical comparison
+			case errX != nil:
+				return 1
+			case errY != nil:
+				return -1
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+		return strings.Compare(lineKey(a, ignoreCase), lineKey(b, ignoreCase))
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if reverse {
+			return compare(lines[j], lines[i]) < 0
+		}
+		return compare(lines[i], lines[j]) < 0
+	})
+}
+
+// countLines counts how often each line occurs, like 'sort | uniq -c' but in
+// order of first appearance. With ignoreCase the first spelling seen is kept.
+func countLines(lines []string, ignoreCase bool) []wordCount {
+	var result []wordCount
+	index := map[string]int{}
+	for _, line := range lines {
+		key := lineKey(line, ignoreCase)
+		if i, ok := index[key]; ok {
+			result[i].Count++
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, wordCount{Word: line, Count: 1})
+	}
+	return result
+}
+
+// textStats summarizes the size and structure of a text.
+type textStats struct {
+	Characters, Words, Lines, Sentences, Paragraphs int
+}
+
+// wordsPerSentence returns the average sentence length, a rough readability hint.
+func (st textStats) wordsPerSentence() float64 {
+	if st.Sentences == 0 {
+		return 0
+	}
+	return float64(st.Words) / float64(st.Sentences)
+}
+
+// endsSentence reports whether a word closes a sentence: it ends in '.', '!'
+// or '?' (ignoring closing quotes and brackets) and isn't an abbreviation,
+// initial ("J.") or dotted acronym ("e.g.").
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, "\"')]}\u201d\u2019")
+	if word == "" || !strings.ContainsRune(".!?", rune(word[len(word)-1])) {
+		return false
+	}
+	if !strings.HasSuffix(word, ".") || strings.HasSuffix(word, "..") {
+		return true
+	}
+	word = strings.ToLower(strings.TrimLeft(word, "\"'([{\u201c\u2018"))
+	if abbreviations[word] || strings.Contains(strings.TrimSuffix(word, "."), ".") {
+		return false
+	}
+	// A single letter is an initial, but a single digit ends "on page 4."
+	first, size := utf8.DecodeRuneInString(word)
+	return size+1 < len(word) || !unicode.IsLetter(first)
+}
+
+// countSentences counts sentences, treating trailing text without final
+// punctuation as one more.
+func countSentences(words []string) int {
+	count := 0
+	for i, w := range words {
+		if endsSentence(w) || i == len(words)-1 {
+			count++
+		}
+	}
+	return count
+}
+
+// countParagraphs counts the blocks of text separated by blank lines.
+func countParagraphs(text string) int {
+	count, inParagraph := 0, false
+	for _, line := range strings.Split(text, "\n") {
+		blank := strings.TrimSpace(line) == ""
+		if !blank && !inParagraph {
+			count++
+		}
+		inParagraph = !blank
+	}
+	return count
+}
+
+// computeStats gathers the statistics shown by 'stats' and the footer.
+func computeStats(text string) textStats {
+	words := strings.Fields(text)
+	return textStats{
+		Characters: utf8.RuneCountInString(text),
+		Words:      len(words),
+		Lines:      strings.Count(text, "\n") + 1,
+		Sentences:  countSentences(words),
+		Paragraphs: countParagraphs(text),
+	}
+}
+
+// diffLine is one line of a diff: ' ' for unchanged, '-' for removed from
+// the first text and '+' for added in the second.
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// diffLines compares a and b line by line using a longest common subsequence.
+// A common prefix and suffix are matched up front to keep the table small.
+func diffLines(a, b []string) ([]diffLine, error) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma) > maxDiffLines || len(mb) > maxDiffLines {
+		return nil, fmt.Errorf("diff is limited to %d changed lines per side", maxDiffLines)
+	}
+
+	// lcs[i][j] is the LCS length of ma[i:] and mb[j:]
+	lcs := make([][]int32, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	result := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		result = append(result, diffLine{' ', line})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			result = append(result, diffLine{' ', ma[i]})
+			i, j = i+1, j+1
+		case i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]):
+			result = append(result, diffLine{'-', ma[i]})
+			i++
+		default:
+			result = append(result, diffLine{'+', mb[j]})
+			j++
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, diffLine{' ', line})
+	}
+	return result, nil
+}
+
+// unifiedDiff formats a diff as hunks with diffContext lines of context,
+// in the style of 'diff -u'. It returns "" if there are no changes.
+func unifiedDiff(lines []diffLine) string {
+	var b strings.Builder
+	// aLine and bLine hold the 1-based line numbers at index i of lines
+	aLine, bLine := make([]int, len(lines)+1), make([]int, len(lines)+1)
+	aLine[0], bLine[0] = 1, 1
+	for i, l := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.Op != '+' {
+			aLine[i+1]++
+		}
+		if l.Op != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk while the next change is within twice the context
+		start, end := max(0, i-diffContext), i
+		for k := i; k < len(lines) && k <= end+2*diffContext; k++ {
+			if lines[k].Op != ' ' {
+				end = k
+			}
+		}
+		end = min(len(lines), end+diffContext+1)
+
+		if b.Len() == 0 {
+			b.WriteString("--- text\n+++ other\n")
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+		for _, l := range lines[start:end] {
+			fmt.Fprintf(&b, "%c%s\n", l.Op, l.Text)
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// cleanURL drops punctuation that ends the surrounding sentence rather than
+// the URL, keeping a closing parenthesis only when the URL opened one.
+func cleanURL(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		if last == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+		if !strings.ContainsRune(".,;:!?)]}", rune(last)) {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+// extractMatches returns the distinct matches of re in text in order of first
+// appearance, passing each through clean if given.
+func extractMatches(text string, re *regexp.Regexp, clean func(string) string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, m := range re.FindAllString(text, -1) {
+		if clean != nil {
+			m = clean(m)
+		}
+		if !seen[m] && !strings.HasSuffix(m, "://") {
+			seen[m] = true
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// hunkRange formats the lines [from, to) of a hunk header. An empty range
+// names the line before it, as 'diff -u' does.
+func hunkRange(from, to int) string {
+	if from == to {
+		return fmt.Sprintf("%d,0", from-1)
+	}
+	return fmt.Sprintf("%d,%d", from, to-from)
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	text := payload.Params["text"]
+	op := payload.Params["op"]
+
+	switch op {
+	case "freq":
+		n, err := strconv.Atoi(payload.Params["n"])
+		if err != nil || n <= 0 {
+			n = 10
+		}
+		skip := payload.Params["stopwords"] == "true"
+		for _, wc := range wordFrequencies(text, n, skip) {
+			fmt.Printf("%s: %d\n", wc.Word, wc.Count)
+		}
+	case "regexmatch":
+		re, err := compilePattern(payload.Params["pattern"], text)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Print(describeMatches(re, text))
+	case "regexreplace":
+		re, err := compilePattern(payload.Params["pattern"], text)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(re.ReplaceAllString(text, payload.Params["replacement"]))
+	case "camel", "pascal", "snake", "kebab", "screaming":
+		result, err := convertCase(text, op)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println(result)
+	case "slug":
+		fmt.Println(slugify(text))
+	case "distance", "similarity":
+		a, b, err := distanceInputs(text, payload.Params["other"])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if op == "distance" {
+			fmt.Println(levenshtein(a, b))
+			break
+		}
+		fmt.Printf("%.4f\n", similarity(a, b))
+	case "rot13":
+		fmt.Println(caesar(text, 13))
+	case "caesar":
+		shift, err := strconv.Atoi(payload.Params["shift"])
+		if err != nil {
+			fmt.Println("Please provide an integer 'shift' parameter.")
+			return
+		}
+		fmt.Println(caesar(text, shift))
+	case "sort":
+		lines := splitLines(text)
+		sortLines(lines, payload.Params["reverse"] == "true", payload.Params["numeric"] == "true",
+			payload.Params["ignorecase"] == "true")
+		fmt.Println(strings.Join(lines, "\n"))
+	case "dedup":
+		for _, lc := range countLines(splitLines(text), payload.Params["ignorecase"] == "true") {
+			fmt.Println(lc.Word)
+		}
+	case "count":
+		for _, lc := range countLines(splitLines(text), payload.Params["ignorecase"] == "true") {
+			fmt.Printf("%7d %s\n", lc.Count, lc.Word)
+		}
+	case "diff":
+		other := payload.Params["other"]
+		if len(text) > maxInputLen || len(other) > maxInputLen {
+			fmt.Printf("Error: inputs must not exceed %d bytes\n", maxInputLen)
+			return
+		}
+		lines, err := diffLines(splitLines(text), splitLines(other))
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if diff := unifiedDiff(lines); diff != "" {
+			fmt.Print(diff)
+		} else {
+			fmt.Println("No differences")
+		}
+	case "links", "emails":
+		if len(text) > maxInputLen {
+			fmt.Printf("Error: input exceeds %d bytes\n", maxInputLen)
+			return
+		}
+		var found []string
+		if op == "links" {
+			found = extractMatches(text, urlPattern, cleanURL)
+		} else {
+			found = extractMatches(text, emailPattern, nil)
+		}
+		if payload.Params["format"] == "json" {
+			data, _ := json.Marshal(found)
+			fmt.Println(string(data))
+			return
+		}
+		for _, f := range found {
+			fmt.Println(f)
+		}
+	case "stats":
+		st := computeStats(text)
+		fmt.Printf("characters: %d\nwords: %d\nlines: %d\nsentences: %d\nparagraphs: %d\nwords per sentence: %.1f\n",
+			st.Characters, st.Words, st.Lines, st.Sentences, st.Paragraphs, st.wordsPerSentence())
+	default:
+		fmt.Printf("Unknown operation '%s'. Supported: %s\n", op, strings.Join(supportedOps, ", "))
+	}
+
+	if !reportOps[op] {
+		return
+	}
+	// Reports end with basic statistics about the input
+	st := computeStats(text)
+	fmt.Printf("\nText statistics: %d characters, %d words, %d lines, %d sentences, %d paragraphs, %.1f words per sentence\n",
+		st.Characters, st.Words, st.Lines, st.Sentences, st.Paragraphs, st.wordsPerSentence())
+}
@@ -0,0 +1,384 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test text_utils.go text_utils_test.go
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWordFrequencies(t *testing.T) {
+	text := `The cat sat on the mat. The dog sat too; the DOG's bowl was empty!
+A cat, a dog and a bird: "Cats" aren't dogs.`
+
+	tests := []struct {
+		n    int
+		skip bool
+		want string
+	}{
+		// Ties are sorted alphabetically, and case and punctuation are ignored
+		{5, false, "[{the 4} {a 3} {cat 2} {dog 2} {sat 2}]"},
+		{0, false, "[{the 4} {a 3} {cat 2} {dog 2} {sat 2} {and 1} {aren't 1} {bird 1} {bowl 1} {cats 1} {dog's 1} {dogs 1} {empty 1} {mat 1} {on 1} {too 1} {was 1}]"},
+		{4, true, "[{cat 2} {dog 2} {sat 2} {aren't 1}]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(wordFrequencies(text, tt.n, tt.skip)); got != tt.want {
+			t.Errorf("n=%d stopwords=%v:\n got %s\nwant %s", tt.n, tt.skip, got, tt.want)
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	text := "2024-01-15 and 2023-12-31, not 99-1-1"
+	re, err := compilePattern(`(\d{4})-(\d{2})-(\d{2})`, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `2 matches
+[0-10] "2024-01-15" $1="2024" $2="01" $3="15"
+[15-25] "2023-12-31" $1="2023" $2="12" $3="31"
+`
+	if got := describeMatches(re, text); got != want {
+		t.Errorf("matches:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Groups that didn't take part in a match are left out
+	re, _ = compilePattern(`(a)|(b)`, "ab")
+	if got, want := describeMatches(re, "ab"), "2 matches\n[0-1] \"a\" $1=\"a\"\n[1-2] \"b\" $2=\"b\"\n"; got != want {
+		t.Errorf("optional groups:\n%s\nwant:\n%s", got, want)
+	}
+	if got := describeMatches(re, "xyz"); got != "0 matches\n" {
+		t.Errorf("no matches: %q", got)
+	}
+
+	// Replacement is global and expands groups
+	if got, want := re.ReplaceAllString("abcab", "[$1$2]"), "[a][b]c[a][b]"; got != want {
+		t.Errorf("replace = %q, want %q", got, want)
+	}
+	re, _ = compilePattern(`(\d{4})-(\d{2})-(\d{2})`, text)
+	if got, want := re.ReplaceAllString(text, "$3.$2.$1"), "15.01.2024 and 31.12.2023, not 99-1-1"; got != want {
+		t.Errorf("replace = %q, want %q", got, want)
+	}
+
+	for pattern, wantErr := range map[string]string{
+		"":          "please provide a 'pattern'",
+		"(unclosed": "invalid pattern",
+		`a**`:       "invalid pattern",
+		`(?<=x)y`:   "invalid pattern",
+	} {
+		if _, err := compilePattern(pattern, text); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("pattern %q: got %v, want %q", pattern, err, wantErr)
+		}
+	}
+	if _, err := compilePattern("a", strings.Repeat("a", maxInputLen+1)); err == nil {
+		t.Error("oversized input accepted")
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"helloWorld", "[hello World]"},
+		{"HTTPServer", "[HTTP Server]"},
+		{"parseHTTPRequest", "[parse HTTP Request]"},
+		{"user_id-2 value", "[user id 2 value]"},
+		{"version2Beta", "[version2 Beta]"},
+		{"  --already_snake__case  ", "[already snake case]"},
+		{"ÜberGroß", "[Über Groß]"},
+		{"", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(splitWords(tt.in)); got != tt.want {
+			t.Errorf("splitWords(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConvertCase(t *testing.T) {
+	tests := []struct {
+		in, style, want string
+	}{
+		{"hello world", "camel", "helloWorld"},
+		{"HTTPServer config", "camel", "httpServerConfig"},
+		{"hello world", "pascal", "HelloWorld"},
+		{"user_id", "pascal", "UserId"},
+		{"parseHTTPRequest", "snake", "parse_http_request"},
+		{"Hello World", "kebab", "hello-world"},
+		{"maxRetryCount", "screaming", "MAX_RETRY_COUNT"},
+		{"some-kebab-case", "camel", "someKebabCase"},
+	}
+	for _, tt := range tests {
+		got, err := convertCase(tt.in, tt.style)
+		if err != nil || got != tt.want {
+			t.Errorf("convertCase(%q, %s) = %q, %v; want %q", tt.in, tt.style, got, err, tt.want)
+		}
+	}
+	if _, err := convertCase("x", "title"); err == nil {
+		t.Error("unknown style accepted")
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Multiple   spaces -- and_dashes ", "multiple-spaces-and-dashes"},
+		{"Crème Brûlée à la carte", "creme-brulee-a-la-carte"},
+		{"Go 1.23 Release", "go-1-23-release"},
+		{"日本語", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b       string
+		dist       int
+		similarity float64
+	}{
+		{"kitten", "sitting", 3, 1 - 3.0/7},
+		{"sitting", "kitten", 3, 1 - 3.0/7},
+		{"flaw", "lawn", 2, 0.5},
+		{"same", "same", 0, 1},
+		{"", "abc", 3, 0},
+		{"", "", 0, 1},
+		// Runes, not bytes, are compared
+		{"Straße", "Strasse", 2, 1 - 2.0/7},
+	}
+	for _, tt := range tests {
+		a, b, err := distanceInputs(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := levenshtein(a, b); got != tt.dist {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.dist)
+		}
+		if got := similarity(a, b); math.Abs(got-tt.similarity) > 1e-9 {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.similarity)
+		}
+	}
+
+	long := strings.Repeat("é", maxDistanceLen)
+	if _, _, err := distanceInputs(long, "x"); err != nil {
+		t.Errorf("%d characters rejected: %v", maxDistanceLen, err)
+	}
+	if _, _, err := distanceInputs("x", long+"é"); err == nil {
+		t.Error("input over the length cap accepted")
+	}
+}
+
+func TestCaesar(t *testing.T) {
+	tests := []struct {
+		in    string
+		shift int
+		want  string
+	}{
+		{"Hello, World!", 3, "Khoor, Zruog!"},
+		{"Khoor, Zruog!", -3, "Hello, World!"},
+		{"abc xyz", -1, "zab wxy"},
+		{"abc", -27, "zab"},
+		{"Hello", 26, "Hello"},
+		{"Hello", -52, "Hello"},
+		{"Hello", 29, "Khoor"},
+		{"Grüße 123", 1, "Hsüßf 123"},
+	}
+	for _, tt := range tests {
+		if got := caesar(tt.in, tt.shift); got != tt.want {
+			t.Errorf("caesar(%q, %d) = %q, want %q", tt.in, tt.shift, got, tt.want)
+		}
+	}
+
+	text := "The Quick Brown Fox Jumps Over The Lazy Dog"
+	if got, want := caesar(text, 13), "Gur Dhvpx Oebja Sbk Whzcf Bire Gur Ynml Qbt"; got != want {
+		t.Errorf("rot13 = %q, want %q", got, want)
+	}
+	if got := caesar(caesar(text, 13), 13); got != text {
+		t.Errorf("rot13 twice = %q", got)
+	}
+	for shift := -30; shift <= 30; shift++ {
+		if got := caesar(caesar(text, shift), -shift); got != text {
+			t.Errorf("shift %d and back = %q", shift, got)
+		}
+	}
+}
+
+func TestSortLines(t *testing.T) {
+	tests := []struct {
+		in                           string
+		reverse, numeric, ignoreCase bool
+		want                         string
+	}{
+		{"pear\nApple\nbanana\napple", false, false, false, "[Apple apple banana pear]"},
+		{"pear\nApple\nbanana\napple", true, false, false, "[pear banana apple Apple]"},
+		// Equal keys keep their input order, in reverse too
+		{"pear\nApple\nbanana\napple", false, false, true, "[Apple apple banana pear]"},
+		{"apple\nBanana\nApple", true, false, true, "[Banana apple Apple]"},
+		{"10\n9\n-2\n 3.5\n100", false, true, false, "[-2  3.5 9 10 100]"},
+		{"10\n9\n-2\n 3.5\n100", true, true, false, "[100 10 9  3.5 -2]"},
+		// Lines that aren't numbers go last, sorted alphabetically
+		{"b\n2\na\n1", false, true, false, "[1 2 a b]"},
+		{"b\n2\nA\n1", false, true, true, "[1 2 A b]"},
+	}
+	for _, tt := range tests {
+		lines := splitLines(tt.in)
+		sortLines(lines, tt.reverse, tt.numeric, tt.ignoreCase)
+		if got := fmt.Sprint(lines); got != tt.want {
+			t.Errorf("sort %q reverse=%v numeric=%v ignorecase=%v = %s, want %s",
+				tt.in, tt.reverse, tt.numeric, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	lines := splitLines("Apple\napple\nbanana\nAPPLE\nBanana\ncherry\n")
+	if got, want := fmt.Sprint(countLines(lines, false)), "[{Apple 1} {apple 1} {banana 1} {APPLE 1} {Banana 1} {cherry 1}]"; got != want {
+		t.Errorf("case-sensitive counts = %s, want %s", got, want)
+	}
+	// The first spelling is kept, in order of first appearance
+	if got, want := fmt.Sprint(countLines(lines, true)), "[{Apple 3} {banana 2} {cherry 1}]"; got != want {
+		t.Errorf("case-insensitive counts = %s, want %s", got, want)
+	}
+	if got := countLines(splitLines(""), true); len(got) != 0 {
+		t.Errorf("empty text counted %v", got)
+	}
+}
+
+func TestCountSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"One. Two! Three?", 3},
+		{"Dr. Smith met Mrs. Jones at 5 p.m. on Main St. today.", 1},
+		{"See e.g. the appendix, i.e. page 4. Then stop.", 2},
+		{"J. R. R. Tolkien wrote books. He died in 1973.", 2},
+		{`She said "Hello." Then she left.`, 2},
+		{"Wait... what?! (Really.) Yes", 4},
+		{"No final punctuation", 1},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := countSentences(strings.Fields(tt.text)); got != tt.want {
+			t.Errorf("countSentences(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	text := "Call me Ishmael. Some years ago, never mind how long, I went to sea.\n\n" +
+		"It is a way I have of driving off the spleen.\n   \n\n" +
+		"Whenever it is a damp, drizzly November in my soul, I account it high time.\n"
+	want := textStats{Characters: len(text), Words: 40, Lines: 7, Sentences: 4, Paragraphs: 3}
+	if got := computeStats(text); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	if got := want.wordsPerSentence(); got != 10 {
+		t.Errorf("words per sentence = %v, want 10", got)
+	}
+
+	for text, want := range map[string]int{
+		"":                      0,
+		"\n\n":                  0,
+		"one":                   1,
+		"one\ntwo":              1,
+		"one\n\ntwo\n\n\nthree": 3,
+		"\n  one\n \t \ntwo\n":  2,
+	} {
+		if got := countParagraphs(text); got != want {
+			t.Errorf("countParagraphs(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	diff := func(a, b string) string {
+		t.Helper()
+		lines, err := diffLines(splitLines(a), splitLines(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return unifiedDiff(lines)
+	}
+	tests := []struct {
+		name, a, b, want string
+	}{
+		{"identical", "a\nb\nc\n", "a\nb\nc\n", ""},
+		{"both empty", "", "", ""},
+		{"insertion", "a\nb\nc", "a\nb\nx\nc", "--- text\n+++ other\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n"},
+		{"deletion", "a\nb\nc", "a\nc", "--- text\n+++ other\n@@ -1,3 +1,2 @@\n a\n-b\n c\n"},
+		{"change", "a\nb\nc", "a\nB\nc", "--- text\n+++ other\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"into empty", "", "x\ny", "--- text\n+++ other\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"to empty", "x\ny", "", "--- text\n+++ other\n@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		// Changes more than twice the context apart get their own hunks
+		{"two hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12", "1\nX\n3\n4\n5\n6\n7\n8\n9\n10\n11\nY",
+			"--- text\n+++ other\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+Y\n"},
+	}
+	for _, tt := range tests {
+		if got := diff(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+
+	// Only the lines between the common prefix and suffix count toward the cap
+	var same, a, b []string
+	for i := range maxDiffLines + 1 {
+		same = append(same, fmt.Sprint("line ", i))
+		a = append(a, fmt.Sprint("a ", i))
+		b = append(b, fmt.Sprint("b ", i))
+	}
+	if _, err := diffLines(same, same); err != nil {
+		t.Errorf("identical long inputs: %v", err)
+	}
+	if _, err := diffLines(append(same, "x"), append(same, "y")); err != nil {
+		t.Errorf("long inputs with one change: %v", err)
+	}
+	if _, err := diffLines(a, b); err == nil || !strings.Contains(err.Error(), "limited to") {
+		t.Errorf("%d changed lines per side: got %v, want the cap", len(a), err)
+	}
+}
+
+func TestExtractMatches(t *testing.T) {
+	text := `See https://example.com/docs. Also (https://en.wikipedia.org/wiki/Go_(programming_language)) and
+"http://foo.test/a?b=c", then https://example.com/docs again; or https:// alone.
+Write to alice@example.com, Bob.Smith+news@mail.example.co.uk or (carol@sub.example.org).
+Not addresses: user@localhost, @handle, x@y.z, 2@3.45.`
+
+	links := extractMatches(text, urlPattern, cleanURL)
+	wantLinks := "[https://example.com/docs https://en.wikipedia.org/wiki/Go_(programming_language) http://foo.test/a?b=c]"
+	if got := fmt.Sprint(links); got != wantLinks {
+		t.Errorf("links:\n got %s\nwant %s", got, wantLinks)
+	}
+	emails := extractMatches(text, emailPattern, nil)
+	wantEmails := "[alice@example.com Bob.Smith+news@mail.example.co.uk carol@sub.example.org]"
+	if got := fmt.Sprint(emails); got != wantEmails {
+		t.Errorf("emails:\n got %s\nwant %s", got, wantEmails)
+	}
+
+	for url, want := range map[string]string{
+		"https://a.test/x.":     "https://a.test/x",
+		"https://a.test/x),":    "https://a.test/x",
+		"https://a.test/(x)":    "https://a.test/(x)",
+		"https://a.test/(x)).":  "https://a.test/(x)",
+		"https://a.test/?q=1!?": "https://a.test/?q=1",
+		"https://a.test/path]}": "https://a.test/path",
+	} {
+		if got := cleanURL(url); got != want {
+			t.Errorf("cleanURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+	if got := extractMatches("nothing here", urlPattern, cleanURL); got == nil || len(got) != 0 {
+		t.Errorf("no links = %#v, want an empty list", got)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// dataDir is where the server mounts the instrument's filesystem.
+const dataDir = "/data"
+
+// maxSide bounds the requested thumbnail dimensions.
+const maxSide = 2048
+
+// maxSourcePixels bounds the size of the source image, which is checked
+// before decoding: a small file can declare a huge image, and decoding it
+// allocates the whole pixel buffer up front.
+const maxSourcePixels = 24_000_000
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// resolvePath maps a file name relative to the mount to an absolute path,
+// rejecting anything that would escape the mounted directory.
+func resolvePath(name string) (string, error) {
+	cleaned := path.Clean(name)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("invalid file path %q", name)
+	}
+	return path.Join(dataDir, cleaned), nil
+}
+
+// readInput returns the image bytes and parameters. A JSON payload names a
+// file under the mount; anything else is taken as the raw image, as sent by
+// raw_stdin routes, with parameters read from THUMBNAIL_* environment variables.
+func readInput() ([]byte, map[string]string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		params := map[string]string{}
+		for _, name := range []string{"width", "height", "fit", "format", "quality"} {
+			params[name] = os.Getenv("THUMBNAIL_" + strings.ToUpper(name))
+		}
+		return data, params, nil
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, nil, fmt.Errorf("decoding JSON: %v", err)
+	}
+	if payload.Params["file"] == "" {
+		return nil, nil, fmt.Errorf("please provide an image in 'file' or as the request body")
+	}
+	file, err := resolvePath(payload.Params["file"])
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %v", payload.Params["file"], err)
+	}
+	return data, payload.Params, nil
+}
+
+// decode decodes the source image, checking its size from the header first.
+func decode(data []byte) (image.Image, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxSourcePixels/cfg.Height {
+		return nil, "", fmt.Errorf("source images are limited to %d pixels, got %dx%d", maxSourcePixels, cfg.Width, cfg.Height)
+	}
+	return image.Decode(bytes.NewReader(data))
+}
+
+// intParam returns the named parameter as an int, or def if absent or invalid.
+func intParam(params map[string]string, name string, def int) int {
+	if v, err := strconv.Atoi(params[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// targetSize works out the thumbnail size and the source region to scale
+// into it. "contain" fits inside width x height keeping the aspect ratio,
+// "cover" fills it by cropping the centre, and "fill" stretches. A missing
+// width or height is derived from the aspect ratio.
+func targetSize(src image.Rectangle, width, height int, fit string) (int, int, image.Rectangle, error) {
+	sw, sh := src.Dx(), src.Dy()
+	if fit != "" && fit != "contain" && fit != "cover" && fit != "fill" {
+		return 0, 0, src, fmt.Errorf("unknown fit '%s'. Supported: contain, cover, fill", fit)
+	}
+	derived := width <= 0 || height <= 0
+	switch {
+	case width <= 0 && height <= 0:
+		return 0, 0, src, fmt.Errorf("please provide 'width' and/or 'height'")
+	case width <= 0:
+		width = max(1, sw*height/sh)
+	case height <= 0:
+		height = max(1, sh*width/sw)
+	}
+	if width > maxSide || height > maxSide {
+		return 0, 0, src, fmt.Errorf("thumbnails are limited to %dx%d", maxSide, maxSide)
+	}
+	if derived {
+		// The aspect ratio is already preserved; fitting again would only add rounding
+		return width, height, src, nil
+	}
+
+	switch fit {
+	case "", "contain":
+		if sw*height > sh*width {
+			height = max(1, sh*width/sw)
+		} else {
+			width = max(1, sw*height/sh)
+		}
+	case "cover":
+		// Crop the source to the target's aspect ratio around its centre
+		if sw*height > sh*width {
+			cw := sh * width / height
+			src.Min.X += (sw - cw) / 2
+			src.Max.X = src.Min.X + cw
+		} else {
+			ch := sw * height / width
+			src.Min.Y += (sh - ch) / 2
+			src.Max.Y = src.Min.Y + ch
+		}
+	}
+	return width, height, src, nil
+}
+
+// resize scales the region src of img to width x height. Each output pixel
+// averages the source pixels it covers (a box filter), which avoids the
+// aliasing of nearest-neighbour sampling when shrinking.
+func resize(img image.Image, src image.Rectangle, width, height int) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Dx(), src.Dy()
+	for y := 0; y < height; y++ {
+		y0 := src.Min.Y + y*sh/height
+		y1 := max(y0+1, src.Min.Y+(y+1)*sh/height)
+		for x := 0; x < width; x++ {
+			x0 := src.Min.X + x*sw/width
+			x1 := max(x0+1, src.Min.X+(x+1)*sw/width)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return out
+}
+
+func main() {
+	data, params, err := readInput()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	img, srcFormat, err := decode(data)
+	if err != nil {
+		fmt.Println("Error decoding image:", err)
+		return
+	}
+
+	width, height, region, err := targetSize(img.Bounds(), intParam(params, "width", 0), intParam(params, "height", 0), params["fit"])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Default to the source format where we can encode it
+	format := params["format"]
+	if format == "" {
+		format = "png"
+		if srcFormat == "jpeg" {
+			format = "jpeg"
+		}
+	}
+	if format != "png" && format != "jpeg" {
+		fmt.Println("Unknown format. Supported: png, jpeg")
+		return
+	}
+
+	thumb := resize(img, region, width, height)
+
+	// The server sniffs the content type from the encoded image
+	out := bufio.NewWriter(os.Stdout)
+	if format == "jpeg" {
+		quality := intParam(params, "quality", jpeg.DefaultQuality)
+		err = jpeg.Encode(out, thumb, &jpeg.Options{Quality: max(1, min(quality, 100))})
+	} else {
+		err = png.Encode(out, thumb)
+	}
+	if err != nil {
+		fmt.Println("Error encoding image:", err)
+		return
+	}
+	out.Flush()
+}
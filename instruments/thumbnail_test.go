@@ -0,0 +1,119 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test thumbnail.go thumbnail_test.go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// fixture returns a PNG of the given size, red on the left half and blue on the right.
+func fixture(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= width/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestThumbnailSizes(t *testing.T) {
+	img, format, err := decode(fixture(t, 400, 200))
+	if err != nil || format != "png" {
+		t.Fatalf("decode: %v, format %q", err, format)
+	}
+
+	tests := []struct {
+		width, height int
+		fit           string
+		wantW, wantH  int
+		wantRegion    image.Rectangle
+	}{
+		{100, 0, "", 100, 50, image.Rect(0, 0, 400, 200)},
+		{0, 50, "", 100, 50, image.Rect(0, 0, 400, 200)},
+		{100, 100, "contain", 100, 50, image.Rect(0, 0, 400, 200)},
+		{100, 100, "cover", 100, 100, image.Rect(100, 0, 300, 200)},
+		{100, 100, "fill", 100, 100, image.Rect(0, 0, 400, 200)},
+	}
+	for _, tt := range tests {
+		w, h, region, err := targetSize(img.Bounds(), tt.width, tt.height, tt.fit)
+		if err != nil {
+			t.Errorf("%dx%d %s: %v", tt.width, tt.height, tt.fit, err)
+			continue
+		}
+		if w != tt.wantW || h != tt.wantH || region != tt.wantRegion {
+			t.Errorf("%dx%d %s: got %dx%d from %v, want %dx%d from %v",
+				tt.width, tt.height, tt.fit, w, h, region, tt.wantW, tt.wantH, tt.wantRegion)
+		}
+		if b := resize(img, region, w, h).Bounds(); b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("%dx%d %s: thumbnail is %v", tt.width, tt.height, tt.fit, b)
+		}
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	img, _, err := decode(fixture(t, 4, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb := resize(img, img.Bounds(), 2, 1)
+	if left, right := thumb.RGBAAt(0, 0), thumb.RGBAAt(1, 0); left != (color.RGBA{255, 0, 0, 255}) || right != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("halves are %v and %v, want red and blue", left, right)
+	}
+	if mixed := resize(img, img.Bounds(), 1, 1).RGBAAt(0, 0); mixed.R < 120 || mixed.R > 135 || mixed.B < 120 || mixed.B > 135 {
+		t.Errorf("single pixel is %v, want the average of red and blue", mixed)
+	}
+}
+
+func TestThumbnailErrors(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	for _, tt := range []struct {
+		width, height int
+		fit           string
+	}{{0, 0, ""}, {maxSide + 1, 10, ""}, {100, 100, "stretch"}} {
+		if _, _, _, err := targetSize(bounds, tt.width, tt.height, tt.fit); err == nil {
+			t.Errorf("%dx%d %q: want an error", tt.width, tt.height, tt.fit)
+		}
+	}
+}
+
+// pngHeader returns the start of a PNG that declares the given size, which
+// is all DecodeConfig reads.
+func pngHeader(width, height uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	out := []byte("\x89PNG\r\n\x1a\n")
+	out = binary.BigEndian.AppendUint32(out, uint32(len(ihdr)-4))
+	out = append(out, ihdr...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestDecodeRejectsHugeImages(t *testing.T) {
+	// Without the check, decoding would try to allocate 3.6 GB before noticing the missing data
+	if _, _, err := decode(pngHeader(30000, 30000)); err == nil || !strings.Contains(err.Error(), "limited") {
+		t.Errorf("30000x30000 source: err = %v, want the size limit", err)
+	}
+	if _, _, err := decode([]byte("not an image")); err == nil {
+		t.Error("garbage accepted")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// algorithms maps the supported HMAC algorithm names to hash constructors.
+var algorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hotp computes an RFC 4226 one-time password for counter.
+func hotp(key []byte, counter uint64, digits int, alg func() hash.Hash) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(alg, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as described in RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// decodeSecret decodes a base32 secret, tolerating spaces, lowercase and missing padding.
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	s = strings.TrimRight(s, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("'secret' must be a non-empty base32 string")
+	}
+	return key, nil
+}
+
+// intParam returns the named parameter as an int, or def if absent or invalid.
+func intParam(params map[string]string, name string, def int) int {
+	if v, err := strconv.Atoi(params[name]); err == nil {
+		return v
+	}
+	return def
+}
+
+// timeStep returns the number of periods since the Unix epoch. An explicit
+// unix 'time' overrides the clock for reproducible output.
+func timeStep(params map[string]string, period int) (uint64, error) {
+	now := time.Now().Unix()
+	if v := params["time"]; v != "" {
+		t, err := strconv.ParseInt(v, 10, 64)
+		// Negative times would wrap around to huge counters
+		if err != nil || t < 0 {
+			return 0, fmt.Errorf("'time' must be a non-negative Unix time")
+		}
+		now = t
+	}
+	return uint64(now / int64(period)), nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	params := payload.Params
+	key, err := decodeSecret(params["secret"])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	period := intParam(params, "period", 30)
+	digits := intParam(params, "digits", 6)
+	window := intParam(params, "window", 1)
+	if period < 1 || digits < 6 || digits > 9 || window < 0 || window > 10 {
+		fmt.Println("Error: 'period' must be positive, 'digits' 6-9 and 'window' 0-10.")
+		return
+	}
+	algName := strings.ToLower(params["algorithm"])
+	if algName == "" {
+		algName = "sha1"
+	}
+	alg, ok := algorithms[algName]
+	if !ok {
+		fmt.Println("Error: unsupported algorithm. Supported: sha1, sha256, sha512")
+		return
+	}
+
+	counter, err := timeStep(params, period)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if params["mode"] != "verify" {
+		fmt.Println(hotp(key, counter, digits, alg))
+		return
+	}
+
+	code := strings.TrimSpace(params["code"])
+	for drift := -window; drift <= window; drift++ {
+		c := int64(counter) + int64(drift)
+		if c < 0 {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(key, uint64(c), digits, alg)), []byte(code)) {
+			fmt.Printf("valid (drift %d)\n", drift)
+			return
+		}
+	}
+	fmt.Println("invalid")
+}
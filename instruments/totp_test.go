@@ -0,0 +1,88 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test totp.go totp_test.go
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D
+	key := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp(key, uint64(counter), 6, algorithms["sha1"]); got != code {
+			t.Errorf("counter %d: %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, 8 digits with a 30 second period
+	keys := map[string][]byte{
+		"sha1":   []byte("12345678901234567890"),
+		"sha256": []byte("12345678901234567890123456789012"),
+		"sha512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		time int64
+		alg  string
+		want string
+	}{
+		{59, "sha1", "94287082"},
+		{59, "sha256", "46119246"},
+		{59, "sha512", "90693936"},
+		{1111111109, "sha1", "07081804"},
+		{1111111109, "sha256", "68084774"},
+		{1234567890, "sha1", "89005924"},
+		{2000000000, "sha512", "38618901"},
+		{20000000000, "sha1", "65353130"},
+		{20000000000, "sha256", "77737706"},
+	}
+	for _, tt := range tests {
+		counter, err := timeStep(map[string]string{"time": strconv.FormatInt(tt.time, 10)}, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hotp(keys[tt.alg], counter, 8, algorithms[tt.alg]); got != tt.want {
+			t.Errorf("%s at %d: %s, want %s", tt.alg, tt.time, got, tt.want)
+		}
+	}
+}
+
+func TestTimeStep(t *testing.T) {
+	for v, want := range map[string]uint64{"0": 0, "29": 0, "30": 1, "59": 1, "1111111109": 37037036} {
+		if got, err := timeStep(map[string]string{"time": v}, 30); err != nil || got != want {
+			t.Errorf("time %s: %d, %v; want %d", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"-1", "-30", "soon", "1.5"} {
+		if got, err := timeStep(map[string]string{"time": v}, 30); err == nil {
+			t.Errorf("time %s accepted as step %d", v, got)
+		}
+	}
+	if got, err := timeStep(map[string]string{}, 30); err != nil || got == 0 {
+		t.Errorf("current time step = %d, %v", got, err)
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	for _, secret := range []string{
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+		"gezd gnbv gy3t qojq gezd gnbv gy3t qojq",
+		"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ====",
+	} {
+		key, err := decodeSecret(secret)
+		if err != nil || string(key) != "12345678901234567890" {
+			t.Errorf("decodeSecret(%q) = %q, %v", secret, key, err)
+		}
+	}
+	for _, secret := range []string{"", "   ", "not base32!", "GEZDG1"} {
+		if _, err := decodeSecret(secret); err == nil {
+			t.Errorf("secret %q accepted", secret)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// factors maps each category to its units' size expressed in the category's
+// base unit (metre, kilogram, byte). Values are exact by definition.
+var factors = map[string]map[string]float64{
+	"length": {
+		"mm": 0.001, "cm": 0.01, "m": 1, "km": 1000,
+		"in": 0.0254, "ft": 0.3048, "yd": 0.9144, "mi": 1609.344, "nmi": 1852,
+	},
+	"mass": {
+		"mg": 1e-6, "g": 0.001, "kg": 1, "t": 1000,
+		"oz": 0.028349523125, "lb": 0.45359237, "st": 6.35029318,
+	},
+	"data": {
+		"b": 0.125, "B": 1,
+		"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+		"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+	},
+}
+
+// temperatureUnits lists the units handled by the offset-based temperature math.
+var temperatureUnits = map[string]bool{"C": true, "F": true, "K": true}
+
+// toKelvin converts a temperature in unit to Kelvin.
+func toKelvin(v float64, unit string) float64 {
+	switch unit {
+	case "C":
+		return v + 273.15
+	case "F":
+		return (v-32)*5/9 + 273.15
+	}
+	return v
+}
+
+// fromKelvin converts a temperature in Kelvin to unit.
+func fromKelvin(v float64, unit string) float64 {
+	switch unit {
+	case "C":
+		return v - 273.15
+	case "F":
+		return (v-273.15)*9/5 + 32
+	}
+	return v
+}
+
+// categoryOf returns the category containing unit, or "" if unknown.
+func categoryOf(unit string) string {
+	if temperatureUnits[unit] {
+		return "temperature"
+	}
+	for name, units := range factors {
+		if _, ok := units[unit]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// convert converts value between two units of the same category.
+func convert(value float64, from, to, category string) (float64, error) {
+	fromCat, toCat := categoryOf(from), categoryOf(to)
+	if fromCat == "" {
+		return 0, fmt.Errorf("unknown unit '%s'", from)
+	}
+	if toCat == "" {
+		return 0, fmt.Errorf("unknown unit '%s'", to)
+	}
+	if fromCat != toCat {
+		return 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromCat, to, toCat)
+	}
+	if category != "" && category != fromCat {
+		return 0, fmt.Errorf("units %s and %s are not in category '%s'", from, to, category)
+	}
+
+	if fromCat == "temperature" {
+		k := toKelvin(value, from)
+		if k < 0 {
+			return 0, fmt.Errorf("temperature is below absolute zero")
+		}
+		return fromKelvin(k, to), nil
+	}
+	return value * factors[fromCat][from] / factors[fromCat][to], nil
+}
+
+// unitList describes the supported units per category.
+func unitList() string {
+	var lines []string
+	for name, units := range factors {
+		names := make([]string, 0, len(units))
+		for u := range units {
+			names = append(names, u)
+		}
+		sort.Strings(names)
+		lines = append(lines, fmt.Sprintf("%s: %s", name, strings.Join(names, ", ")))
+	}
+	lines = append(lines, "temperature: C, F, K")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	params := payload.Params
+	value, err := strconv.ParseFloat(params["value"], 64)
+	if err != nil {
+		fmt.Println("Please provide a numeric 'value' and 'from'/'to' units. Supported units:")
+		fmt.Println(unitList())
+		return
+	}
+
+	result, err := convert(value, params["from"], params["to"], params["category"])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("%s %s = %s %s\n", strconv.FormatFloat(value, 'g', -1, 64), params["from"],
+		strconv.FormatFloat(result, 'g', 12, 64), params["to"])
+}
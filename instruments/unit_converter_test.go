@@ -0,0 +1,106 @@
+package main
+
+// Instruments are separate programs, so test them one file at a time:
+//
+//	go test unit_converter.go unit_converter_test.go
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// near reports whether a and b agree to within a relative 1e-12.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-12*math.Max(1, math.Abs(b))
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{1, "mi", "km", 1.609344},
+		{1, "ft", "in", 12},
+		{3, "ft", "yd", 1},
+		{1, "nmi", "m", 1852},
+		{250, "mm", "m", 0.25},
+		{1, "lb", "kg", 0.45359237},
+		{16, "oz", "lb", 1},
+		{1, "st", "lb", 14},
+		{2.5, "t", "kg", 2500},
+		{1, "GiB", "MiB", 1024},
+		{1, "GB", "MB", 1000},
+		{1, "KiB", "B", 1024},
+		{8, "b", "B", 1},
+		{1, "TB", "GiB", 931.3225746154785},
+		{42, "m", "m", 42},
+	}
+	for _, tt := range tests {
+		got, err := convert(tt.value, tt.from, tt.to, "")
+		if err != nil || !near(got, tt.want) {
+			t.Errorf("%v %s in %s = %v, %v; want %v", tt.value, tt.from, tt.to, got, err, tt.want)
+		}
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	// Each row is the same temperature in C, F and K
+	points := [][3]float64{
+		{0, 32, 273.15},
+		{100, 212, 373.15},
+		{-40, -40, 233.15},
+		{37, 98.6, 310.15},
+		{-273.15, -459.67, 0},
+	}
+	units := []string{"C", "F", "K"}
+	for _, p := range points {
+		for i, from := range units {
+			for j, to := range units {
+				got, err := convert(p[i], from, to, "temperature")
+				if err != nil || !near(got, p[j]) {
+					t.Errorf("%v %s in %s = %v, %v; want %v", p[i], from, to, got, err, p[j])
+				}
+			}
+		}
+	}
+	// Going around C -> F -> K -> C returns the starting value
+	for _, c := range []float64{-100, 0, 21.5, 1000} {
+		f, _ := convert(c, "C", "F", "")
+		k, _ := convert(f, "F", "K", "")
+		back, _ := convert(k, "K", "C", "")
+		if !near(back, c) {
+			t.Errorf("%v C around the triangle came back as %v", c, back)
+		}
+	}
+	for _, tt := range []struct {
+		value float64
+		unit  string
+	}{{-274, "C"}, {-460, "F"}, {-1, "K"}} {
+		if _, err := convert(tt.value, tt.unit, "K", ""); err == nil || !strings.Contains(err.Error(), "absolute zero") {
+			t.Errorf("%v %s: got %v, want a below absolute zero error", tt.value, tt.unit, err)
+		}
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	for _, tt := range []struct {
+		from, to, category, want string
+	}{
+		{"parsec", "m", "", "unknown unit 'parsec'"},
+		{"m", "", "", "unknown unit ''"},
+		{"kg", "m", "", "cannot convert kg (mass) to m (length)"},
+		{"C", "kg", "", "cannot convert C (temperature) to kg (mass)"},
+		{"m", "km", "mass", "not in category 'mass'"},
+		// Unit names are case-sensitive: b is bits, B bytes
+		{"kb", "B", "", "unknown unit 'kb'"},
+	} {
+		if _, err := convert(1, tt.from, tt.to, tt.category); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s to %s (%s): got %v, want %q", tt.from, tt.to, tt.category, err, tt.want)
+		}
+	}
+	if list := unitList(); !strings.Contains(list, "length: cm, ft, in, km, m, mi, mm, nmi, yd") || !strings.Contains(list, "temperature: C, F, K") {
+		t.Errorf("unit list:\n%s", list)
+	}
+}
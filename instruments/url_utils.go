@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+type Payload struct {
+	Params map[string]string `json:"params"`
+}
+
+// defaultPorts maps schemes to the port that can be omitted from a canonical URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// normalize returns a canonical form of rawURL suitable for dedup and cache keys.
+func normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("URL must be absolute")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimRight(strings.ToLower(host), ".")
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	// Work on the escaped path, as decoding it would turn e.g. %2F into a
+	// separator and so name a different resource
+	escaped := removeDotSegments(normalizeEscapes(u.EscapedPath()))
+	if escaped == "" {
+		escaped = "/"
+	}
+	if u.Path, err = url.PathUnescape(escaped); err != nil {
+		return "", err
+	}
+	u.RawPath = escaped
+
+	u.RawQuery = u.Query().Encode()
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+// unreserved reports whether c is an RFC 3986 unreserved character, which
+// means the same whether percent-encoded or not.
+func unreserved(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and
+// uppercases the hex digits of all other escapes, as in RFC 3986 section 6.2.2.
+func normalizeEscapes(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] == '%' && i+2 < len(p) {
+			if v, err := strconv.ParseUint(p[i+1:i+3], 16, 8); err == nil {
+				if c := byte(v); unreserved(c) {
+					b.WriteByte(c)
+				} else {
+					b.WriteString("%" + strings.ToUpper(p[i+1:i+3]))
+				}
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(p[i])
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." path segments as described in RFC 3986.
+func removeDotSegments(p string) string {
+	if p == "" {
+		return ""
+	}
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		switch seg {
+		case ".":
+			if i == len(segments)-1 {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if i == len(segments)-1 {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	result := strings.Join(out, "/")
+	if !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
+
+// idnaProfile converts hosts with the lookup mapping but rejects anything
+// that isn't a valid DNS name, such as empty labels ("a..b"), labels that
+// are too long, disallowed runes and hyphens in the wrong places.
+var idnaProfile = idna.New(
+	idna.MapForLookup(),
+	idna.VerifyDNSLength(true),
+	idna.StrictDomainName(true),
+	idna.BidiRule(),
+)
+
+// checkALabels rejects A-labels ("xn--...") that aren't the canonical
+// encoding of a Unicode label. The idna package decodes e.g. "xn--invalid-"
+// to plain "invalid" without complaint.
+func checkALabels(host string) error {
+	for _, label := range strings.Split(host, ".") {
+		if !strings.HasPrefix(strings.ToLower(label), "xn--") {
+			continue
+		}
+		unicode, err := idnaProfile.ToUnicode(label)
+		if err != nil {
+			return err
+		}
+		if ascii, err := idnaProfile.ToASCII(unicode); err != nil || ascii != strings.ToLower(label) {
+			return fmt.Errorf("idna: %q is not a canonical A-label", label)
+		}
+	}
+	return nil
+}
+
+// idnToASCII converts host to its ASCII (Punycode) wire format.
+func idnToASCII(host string) (string, error) {
+	if err := checkALabels(host); err != nil {
+		return "", err
+	}
+	return idnaProfile.ToASCII(host)
+}
+
+// idnToUnicode converts host to Unicode for display. The host is validated
+// through its ASCII form, as ToUnicode doesn't check label lengths.
+func idnToUnicode(host string) (string, error) {
+	if _, err := idnToASCII(host); err != nil {
+		return "", err
+	}
+	return idnaProfile.ToUnicode(host)
+}
+
+// convertHost applies conv to the host of input, which may be a bare host or a full URL.
+func convertHost(input string, conv func(string) (string, error)) (string, error) {
+	input = strings.TrimSpace(input)
+	if !strings.Contains(input, "://") {
+		return conv(input)
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", err
+	}
+	host, err := conv(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	// Rebuild the URL by hand so a Unicode host is not percent-escaped
+	prefix := u.Scheme + "://"
+	if u.User != nil {
+		prefix += u.User.String() + "@"
+	}
+	rest := *u
+	rest.Scheme, rest.User, rest.Host = "", nil, ""
+	return prefix + host + rest.String(), nil
+}
+
+func main() {
+	decoder := json.NewDecoder(os.Stdin)
+	var payload Payload
+	if err := decoder.Decode(&payload); err != nil {
+		fmt.Println("Error decoding JSON:", err)
+		return
+	}
+
+	op := payload.Params["op"]
+	input := payload.Params["url"]
+	if input == "" {
+		input = payload.Params["host"]
+	}
+	if input == "" {
+		fmt.Println("Please provide a 'url' or 'host' parameter.")
+		return
+	}
+
+	switch op {
+	case "normalize":
+		result, err := normalize(input)
+		if err != nil {
+			fmt.Println("Error normalizing URL:", err)
+			return
+		}
+		fmt.Println(result)
+	case "idn-encode":
+		// Convert Unicode labels to their ASCII (Punycode) wire format
+		result, err := convertHost(input, idnToASCII)
+		if err != nil {
+			fmt.Println("Error encoding host:", err)
+			return
+		}
+		fmt.Println(result)
+	case "idn-decode":
+		// Convert Punycode labels back to Unicode for display
+		result, err := convertHost(input, idnToUnicode)
+		if err != nil {
+			fmt.Println("Error decoding host:", err)
+			return
+		}
+		fmt.Println(result)
+	default:
+		fmt.Printf("Unknown operation '%s'. Supported: normalize, idn-encode, idn-decode\n", op)
+	}
+}
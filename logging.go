@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Route log levels. Silent routes log nothing per request, normal ones log
+// errors and the instrument's stderr, and debug ones also log run times and
+// the payload sent to the instrument.
+const (
+	logSilent = "silent"
+	logNormal = "normal"
+	logDebug  = "debug"
+)
+
+// logLevels ranks the log levels; an empty level means normal.
+var logLevels = map[string]int{logSilent: 0, "": 1, logNormal: 1, logDebug: 2}
+
+// logs reports whether the route logs messages of the given level.
+func (route Route) logs(level string) bool {
+	return logLevels[route.LogLevel] >= logLevels[level]
+}
+
+// logf logs a message for the route if its log level includes level.
+func (route Route) logf(level, format string, args ...any) {
+	if route.logs(level) {
+		log.Printf(format, args...)
+	}
+}
+
+// stderrLogger logs each line an instrument writes to stderr.
+type stderrLogger struct {
+	prefix string
+}
+
+func (l stderrLogger) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		log.Printf("%s%s", l.prefix, line)
+	}
+	return len(p), nil
+}
+
+// sensitiveParams are substrings of parameter names whose values are never logged.
+var sensitiveParams = []string{"password", "passwd", "secret", "token", "key", "auth", "session"}
+
+// redactPayload describes a payload for debug logs, masking the values of
+// sensitive-looking parameters and of all cookies.
+func redactPayload(payload RequestPayload) string {
+	params := make(map[string]string, len(payload.Params))
+	for name, value := range payload.Params {
+		params[name] = value
+		for _, s := range sensitiveParams {
+			if strings.Contains(strings.ToLower(name), s) {
+				params[name] = "[redacted]"
+				break
+			}
+		}
+	}
+	cookies := make([]string, 0, len(payload.Cookies))
+	for name := range payload.Cookies {
+		cookies = append(cookies, name)
+	}
+	sort.Strings(cookies)
+	return fmt.Sprintf("params=%v seed=%d lang=%q cookies=%v", params, payload.Seed, payload.Lang, cookies)
+}
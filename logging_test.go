@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogLevels(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/silent": {"wasm_file": "{echo}", "log_level": "silent"},
+		"/normal": {"wasm_file": "{echo}"},
+		"/debug": {"wasm_file": "{echo}", "log_level": "debug"}
+	}}`)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		path          string
+		want, notWant []string
+	}{
+		{"/silent", nil, []string{"from stderr", "Error running", "ran in", "hunter2"}},
+		{"/normal", []string{"stderr: from stderr", "Error running"}, []string{"ran in", "hunter2"}},
+		{"/debug", []string{"stderr: from stderr", "Error running", "ran in", "password:[redacted]"}, []string{"hunter2"}},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		w := serve(s, "GET", tt.path+"?stderr=from+stderr&password=hunter2&exit=3")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: status = %d, want 500", tt.path, w.Code)
+		}
+		logged := buf.String()
+		for _, want := range tt.want {
+			if !strings.Contains(logged, want) {
+				t.Errorf("%s: log lacks %q:\n%s", tt.path, want, logged)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(logged, notWant) {
+				t.Errorf("%s: log contains %q:\n%s", tt.path, notWant, logged)
+			}
+		}
+	}
+}
+
+func TestUnknownLogLevel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`{"routes": {"/x": {"wasm_file": "x.wasm", "log_level": "verbose"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigs(file); err == nil || !strings.Contains(err.Error(), "verbose") {
+		t.Errorf("err = %v, want the unknown level reported", err)
+	}
+}
@@ -2,39 +2,122 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	cryptorand "crypto/rand"
+	"crypto/subtle"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"mime"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxRawBodySize limits request bodies piped to raw_stdin instruments.
+const maxRawBodySize = 32 << 20
+
+// defaultFavicon is served for /favicon.ico unless a route or Config.Favicon overrides it.
+//
+//go:embed assets/favicon.ico
+var defaultFavicon []byte
+
 // Config represents the server configuration, including routes and caching settings.
 type Config struct {
-	Port      string           `json:"port"`
-	Routes    map[string]Route `json:"routes"`
-	CacheTTL  int              `json:"cache_ttl"`
-	CacheSize int              `json:"cache_size"`
+	Port          string           `json:"port"`
+	Routes        map[string]Route `json:"routes"`
+	CacheTTL      int              `json:"cache_ttl"`
+	CacheSize     int              `json:"cache_size"`
+	BasicAuth     *BasicAuth       `json:"basic_auth"`
+	ErrorPages    map[int]string   `json:"error_pages"`
+	Favicon       string           `json:"favicon"`
+	BasePath      string           `json:"base_path"`
+	TrailingSlash string           `json:"trailing_slash"`
+	Tracing       *TracingConfig   `json:"tracing"`
+	RuntimeMode   string           `json:"runtime_mode"`
+	AccessLog     *AccessLogConfig `json:"access_log"`
+	Security      *SecurityHeaders `json:"security_headers"`
+	// ShutdownTimeout bounds, in seconds, how long shutdown waits for in-flight requests.
+	ShutdownTimeout int `json:"shutdown_timeout"`
+	// CompileTimeout bounds, in seconds, how long compiling a module may take.
+	CompileTimeout int `json:"compile_timeout"`
+	// MemoryLimit caps, in MiB, the linear memory of each instrument instance.
+	MemoryLimit int `json:"memory_limit_mb"`
+	Static      struct {
+		Prefix string `json:"prefix"`
+		Dir    string `json:"dir"`
+	} `json:"static"`
 }
 
 // Route defines a server route mapped to a WASM instrument.
 type Route struct {
-	Path       string `json:"path"`
-	WasmFile   string `json:"wasm_file"`
-	Cache      bool   `json:"cache"`
-	TTL        int    `json:"ttl"`
+	// Path is the route's key in the config, e.g. "/calc/:op", set by matchRoute.
+	Path          string            `json:"path"`
+	WasmFile      string            `json:"wasm_file"`
+	Cache         bool              `json:"cache"`
+	TTL           *int              `json:"ttl"`
+	Env           map[string]string `json:"env"`
+	DefaultParams map[string]string `json:"default_params"`
+	Alternatives  map[string]string `json:"alternatives"`
+	Variants      []Variant         `json:"variants"`
+	// StickyVariants pins each client to its first variant with a cookie.
+	StickyVariants bool `json:"sticky_variants"`
+	// RawStdin pipes the request body to the instrument instead of the JSON payload.
+	RawStdin bool `json:"raw_stdin"`
+	// Stream sends output to the client as it is written instead of buffering it.
+	Stream bool `json:"stream"`
+	// Headers lets the instrument start its output with a header block; see headers.go.
+	Headers bool `json:"headers"`
+	// Cookies passes the request's cookies to the instrument.
+	Cookies    bool       `json:"cookies"`
+	APIKeys    []string   `json:"api_keys"`
+	BasicAuth  *BasicAuth `json:"basic_auth"`
 	Filesystem struct {
 		Mount string `json:"mount"`
 		Path  string `json:"path"`
 	} `json:"filesystem"`
+	// UploadMount is where multipart uploads are mounted in the instrument.
+	UploadMount string `json:"upload_mount"`
+	// MaxConcurrent limits simultaneous runs; excess requests wait up to
+	// QueueTimeoutMS for a slot before getting 503.
+	MaxConcurrent  int `json:"max_concurrent"`
+	QueueTimeoutMS int `json:"queue_timeout_ms"`
+	// HideSeed omits the X-WASIO-Seed header, for instruments whose output
+	// must not be reproducible by whoever sees the response.
+	HideSeed bool `json:"hide_seed"`
+	// LogLevel is silent, normal (the default) or debug; see logging.go.
+	LogLevel string `json:"log_level"`
+
+	// uploadDir holds this request's uploaded files, if any.
+	uploadDir string
+	// slots is the route's concurrency semaphore, shared by all copies of the route.
+	slots chan struct{}
+}
+
+// Variant is a weighted alternative WASM file for gradual rollouts.
+type Variant struct {
+	WasmFile string `json:"wasm_file"`
+	Weight   int    `json:"weight"`
 }
 
 // Server represents the main server with configuration, caching, and Instruments.
@@ -42,6 +125,7 @@ type Server struct {
 	config      *Config
 	moduleCache *ModuleCache
 	cache       *ResponseCache
+	ready       atomic.Bool
 }
 
 // ModuleCache manages cached compiled modules.
@@ -49,6 +133,41 @@ type ModuleCache struct {
 	cache map[string]wazero.CompiledModule
 	mu    sync.RWMutex
 	rt    wazero.Runtime
+
+	// compileTimeout bounds how long a request waits for a compilation; zero means no limit
+	compileTimeout time.Duration
+	// compileFunc is rt.CompileModule, except in tests
+	compileFunc func(context.Context, []byte) (wazero.CompiledModule, error)
+	// compiling holds the compilations in progress, failed the remembered failures
+	compiling map[string]*compileCall
+	failed    map[string]compileFailure
+
+	// running tracks in-flight executions so shutdown can drain them;
+	// once draining is set under drainMu no new ones are admitted
+	running  sync.WaitGroup
+	active   atomic.Int64
+	drainMu  sync.Mutex
+	draining bool
+}
+
+// errShuttingDown is returned for executions started after Drain.
+var errShuttingDown = errors.New("server is shutting down")
+
+// compileRetryDelay is how long a failed compilation is remembered, so a
+// broken or pathologically slow module isn't compiled again on every request.
+const compileRetryDelay = time.Minute
+
+// compileCall is a compilation in progress, shared by the requests waiting for it.
+type compileCall struct {
+	done   chan struct{}
+	module wazero.CompiledModule
+	err    error
+}
+
+// compileFailure is a remembered compilation error.
+type compileFailure struct {
+	err   error
+	until time.Time
 }
 
 // ResponseCache manages cached responses with TTLs.
@@ -65,33 +184,83 @@ type CachedResponse struct {
 
 // RequestPayload represents data sent to WASM.
 type RequestPayload struct {
-	Params map[string]string `json:"params"`
-	Seed   int64             `json:"seed"`
+	Params    map[string]string `json:"params"`
+	Seed      int64             `json:"seed"`
+	RequestID string            `json:"request_id,omitempty"`
+	Lang      string            `json:"lang,omitempty"`
+	Cookies   map[string]string `json:"cookies,omitempty"`
 }
 
 // NewConfig loads configuration from a JSON file.
 func NewConfig(filename string) (*Config, error) {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %v", err)
-	}
+	return LoadConfigs(filename)
+}
 
+// LoadConfigs loads and merges configuration files in order. Each file
+// overrides the fields it sets. Routes are merged by path: a route in a later
+// file replaces the route with the same path as a whole, and new paths are
+// added.
+func LoadConfigs(filenames ...string) (*Config, error) {
 	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		// Decoding into the same struct keeps fields the file doesn't mention
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", filename, err)
+		}
+	}
+	for path, route := range config.Routes {
+		if _, ok := logLevels[route.LogLevel]; !ok {
+			return nil, fmt.Errorf("route %s: unknown log_level %q", path, route.LogLevel)
+		}
+		if route.MaxConcurrent > 0 {
+			route.slots = make(chan struct{}, route.MaxConcurrent)
+			config.Routes[path] = route
+		}
 	}
 	return &config, nil
 }
 
-// NewModuleCache initializes the module cache.
-func NewModuleCache() *ModuleCache {
+// defaultMemoryLimit is the per-instance memory limit in MiB when the config
+// sets none.
+const defaultMemoryLimit = 256
+
+// NewModuleCache initializes the module cache with a runtime in the given
+// mode: "compiler" or "interpreter" for platforms without JIT. An empty mode
+// uses the compiler where wazero supports it. Each instance may grow its
+// memory to at most memoryLimit MiB.
+func NewModuleCache(mode string, memoryLimit int) (*ModuleCache, error) {
+	var rc wazero.RuntimeConfig
+	switch mode {
+	case "":
+		rc = wazero.NewRuntimeConfig()
+	case "compiler":
+		rc = wazero.NewRuntimeConfigCompiler()
+	case "interpreter":
+		rc = wazero.NewRuntimeConfigInterpreter()
+	default:
+		return nil, fmt.Errorf("unknown runtime mode %q", mode)
+	}
+	if memoryLimit <= 0 {
+		memoryLimit = defaultMemoryLimit
+	}
+	// Wasm memory grows in 64 KiB pages, and 32-bit modules can't address more than 4 GiB
+	pages := min(memoryLimit*16, 65536)
+
 	ctx := context.Background()
-	rt := wazero.NewRuntime(ctx)
+	// Close instances when their request is canceled, so abandoned runs stop using CPU
+	rt := wazero.NewRuntimeWithConfig(ctx, rc.WithCloseOnContextDone(true).WithMemoryLimitPages(uint32(pages)))
 	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
 	return &ModuleCache{
-		cache: make(map[string]wazero.CompiledModule),
-		rt:    rt,
-	}
+		cache:       make(map[string]wazero.CompiledModule),
+		rt:          rt,
+		compileFunc: rt.CompileModule,
+		compiling:   make(map[string]*compileCall),
+		failed:      make(map[string]compileFailure),
+	}, nil
 }
 
 // NewResponseCache initializes the response cache.
@@ -111,7 +280,11 @@ func (rc *ResponseCache) GetCachedResponse(key string) ([]byte, bool) {
 }
 
 // SetCachedResponse saves a response in the cache with a specified TTL.
+// A TTL of zero or less stores nothing, so the next request runs again.
 func (rc *ResponseCache) SetCachedResponse(key string, value []byte, ttl int) {
+	if ttl <= 0 {
+		return
+	}
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 
@@ -121,99 +294,697 @@ func (rc *ResponseCache) SetCachedResponse(key string, value []byte, ttl int) {
 	}
 }
 
+// ttl returns the route's cache lifetime in seconds: its own TTL if set,
+// including an explicit 0, otherwise the global default.
+func (route Route) ttl(global int) int {
+	if route.TTL != nil {
+		return *route.TTL
+	}
+	return global
+}
+
+// matchRoute finds the route for path. Exact routes win; otherwise route keys
+// containing ":name" segments are tried in sorted order, and the matched
+// segments are returned as parameters. The route's Path is set to the
+// matching key.
+func (s *Server) matchRoute(path string) (Route, map[string]string, bool) {
+	if route, ok := s.config.Routes[path]; ok {
+		route.Path = path
+		return route, nil, true
+	}
+
+	patterns := make([]string, 0, len(s.config.Routes))
+	for pattern := range s.config.Routes {
+		if strings.Contains(pattern, "/:") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, pattern := range patterns {
+		parts := strings.Split(strings.Trim(pattern, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		for i, part := range parts {
+			if strings.HasPrefix(part, ":") && segments[i] != "" {
+				params[part[1:]] = segments[i]
+			} else if part != segments[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil {
+			route := s.config.Routes[pattern]
+			route.Path = pattern
+			return route, params, true
+		}
+	}
+	return Route{}, nil, false
+}
+
+// selectWasmFile picks the route's WASM file for the Accept header, preferring
+// the alternative with the highest quality value and falling back to WasmFile.
+func selectWasmFile(route Route, accept string) string {
+	best, bestQ := route.WasmFile, 0.0
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		file, ok := route.Alternatives[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = file, q
+		}
+	}
+	return best
+}
+
+// variantCookie holds the index of the variant a client was assigned.
+const variantCookie = "wasio_variant"
+
+// pickVariant returns the index of a variant chosen at random in proportion
+// to its weight. Variants with non-positive weights are never picked.
+func pickVariant(variants []Variant) int {
+	total := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	n := rand.Intn(total)
+	for i, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if n < v.Weight {
+			return i
+		}
+		n -= v.Weight
+	}
+	return len(variants) - 1
+}
+
+// selectVariant picks the route's variant for r, reusing the client's
+// assignment from the cookie when the route is sticky.
+func (s *Server) selectVariant(route Route, w http.ResponseWriter, r *http.Request) string {
+	if route.StickyVariants {
+		if c, err := r.Cookie(variantCookie); err == nil {
+			if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(route.Variants) {
+				return route.Variants[i].WasmFile
+			}
+		}
+	}
+	i := pickVariant(route.Variants)
+	if route.StickyVariants {
+		http.SetCookie(w, &http.Cookie{Name: variantCookie, Value: strconv.Itoa(i), Path: s.basePrefix() + r.URL.Path, HttpOnly: true})
+	}
+	return route.Variants[i].WasmFile
+}
+
+// preferredLanguage returns the language tag the Accept-Language header
+// ranks highest, or "" if there is none.
+func preferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// authorized reports whether the request carries one of the route's API keys,
+// either in the X-API-Key header or the api_key query parameter. Routes
+// without keys are open.
+func authorized(route Route, r *http.Request) bool {
+	if len(route.APIKeys) == 0 {
+		return true
+	}
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = r.URL.Query().Get("api_key")
+	}
+	if key == "" {
+		return false
+	}
+	ok := false
+	for _, valid := range route.APIKeys {
+		// Compare against every key so timing does not reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// acquireSlot takes one of the route's concurrency slots, waiting in line up
+// to the route's queue timeout. It reports false if no slot became free.
+func acquireSlot(ctx context.Context, route Route) bool {
+	select {
+	case route.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if route.QueueTimeoutMS <= 0 {
+		return false
+	}
+	timer := time.NewTimer(time.Duration(route.QueueTimeoutMS) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case route.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sendsSeed reports whether responses carry the X-WASIO-Seed header:
+// raw_stdin instruments never receive a seed, and hide_seed routes keep it secret.
+func (route Route) sendsSeed() bool {
+	return !route.RawStdin && !route.HideSeed
+}
+
+// basePrefix returns the configured base path as "/prefix", or "" if unset.
+func (s *Server) basePrefix() string {
+	if base := strings.Trim(s.config.BasePath, "/"); base != "" {
+		return "/" + base
+	}
+	return ""
+}
+
+// wantsJSON reports whether the client's Accept header asks for JSON.
+func wantsJSON(r *http.Request) bool {
+	for _, entry := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(entry, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "application/json") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError responds with a JSON error for clients accepting JSON, or with
+// the configured error page for status, falling back to a plain-text message
+// when none is configured or it can't be read.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(struct {
+			Error     string `json:"error"`
+			Status    int    `json:"status"`
+			RequestID string `json:"request_id,omitempty"`
+		}{message, status, requestIDFrom(r.Context())})
+		return
+	}
+	if page, ok := s.config.ErrorPages[status]; ok {
+		if body, err := os.ReadFile(page); err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write(body)
+			return
+		}
+		log.Printf("Error page for %d unavailable: %s", status, page)
+	}
+	http.Error(w, message, status)
+}
+
+// serveFavicon serves the configured favicon, or the embedded default, with a
+// long cache lifetime so browsers stop asking for it.
+func (s *Server) serveFavicon(w http.ResponseWriter) {
+	icon, contentType := defaultFavicon, "image/x-icon"
+	if s.config.Favicon != "" {
+		data, err := os.ReadFile(s.config.Favicon)
+		if err != nil {
+			log.Printf("Favicon unavailable, using default: %v", err)
+		} else {
+			icon = data
+			if ct := mime.TypeByExtension(filepath.Ext(s.config.Favicon)); ct != "" {
+				contentType = ct
+			}
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	w.Write(icon)
+}
+
+// noListingFS hides directories without an index.html so the static file
+// server doesn't expose directory listings.
+type noListingFS struct {
+	http.FileSystem
+}
+
+// Open opens name, rejecting directories that have no index.html.
+func (fs noListingFS) Open(name string) (http.File, error) {
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		index, err := fs.FileSystem.Open(strings.TrimSuffix(name, "/") + "/index.html")
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
+
 // ServeHTTP routes requests to the appropriate WASM instrument and handles caching.
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	route, exists := s.config.Routes[r.URL.Path]
+	route, pathParams, exists := s.matchRoute(r.URL.Path)
+	// Probes stay open so they work without credentials. A deep health check
+	// compiles every module, so it needs them like any other request.
+	deep := r.URL.Query().Get("deep") == "true"
+	if !exists && r.URL.Path == "/health" && !deep {
+		s.serveHealth(w, r)
+		return
+	}
+	if !exists && (r.URL.Path == "/healthz" || r.URL.Path == "/readyz") {
+		s.serveProbe(w, r)
+		return
+	}
+	if ba := s.config.BasicAuth; ba != nil && !ba.Check(r) {
+		ba.Challenge(w, r, s.writeError)
+		return
+	}
+	if !exists && r.URL.Path == "/health" {
+		s.serveHealth(w, r)
+		return
+	}
+	if !exists && r.URL.Path == "/favicon.ico" {
+		s.serveFavicon(w)
+		return
+	}
+	// Static files never shadow configured routes
+	static := s.config.Static
+	if !exists && static.Prefix != "" && static.Dir != "" && strings.HasPrefix(r.URL.Path, static.Prefix) {
+		fs := noListingFS{http.Dir(static.Dir)}
+		http.StripPrefix(static.Prefix, http.FileServer(fs)).ServeHTTP(w, r)
+		return
+	}
+	if !exists && s.config.TrailingSlash != "" && r.URL.Path != "/" {
+		alt := r.URL.Path + "/"
+		if strings.HasSuffix(r.URL.Path, "/") {
+			alt = strings.TrimSuffix(r.URL.Path, "/")
+		}
+		if altRoute, altParams, ok := s.matchRoute(alt); ok {
+			switch s.config.TrailingSlash {
+			case "redirect":
+				target := s.basePrefix() + alt
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			case "ignore":
+				route, pathParams, exists = altRoute, altParams, true
+			}
+		}
+	}
 	if !exists {
-		http.Error(w, "404 - Not Found", http.StatusNotFound)
+		s.writeError(w, r, http.StatusNotFound, "404 - Not Found")
+		return
+	}
+	if route.BasicAuth != nil && !route.BasicAuth.Check(r) {
+		route.BasicAuth.Challenge(w, r, s.writeError)
+		return
+	}
+	if !authorized(route, r) {
+		s.writeError(w, r, http.StatusUnauthorized, "401 - Unauthorized")
 		return
 	}
 
-	cacheKey := r.URL.Path + r.URL.RawQuery
+	if len(route.Variants) > 0 {
+		route.WasmFile = s.selectVariant(route, w, r)
+		route.logf(logNormal, "[%s] Serving %s with variant %s", requestIDFrom(r.Context()), r.URL.Path, route.WasmFile)
+	}
+	if len(route.Alternatives) > 0 {
+		route.WasmFile = selectWasmFile(route, r.Header.Get("Accept"))
+		w.Header().Add("Vary", "Accept")
+	}
+
+	span := trace.SpanFromContext(r.Context())
+	span.SetAttributes(attribute.String("wasio.route", route.Path), attribute.String("wasio.wasm_file", route.WasmFile))
+
+	// The body and cookies aren't part of the cache key, so raw-body, upload
+	// and cookie responses are never cached. Streamed output isn't kept to cache.
+	uploads := route.UploadMount != "" && isMultipart(r)
+	if route.RawStdin || uploads || route.Cookies || route.Stream {
+		route.Cache = false
+	}
+	// Only safe methods may be answered from, or populate, the cache
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		route.Cache = false
+	}
+	// Instruments may localize their output, so the language is part of the key
+	lang := preferredLanguage(r.Header.Get("Accept-Language"))
+	cacheKey := route.WasmFile + " " + lang + " " + r.URL.Path + r.URL.RawQuery
 	if route.Cache {
-		if cached, found := s.cache.GetCachedResponse(cacheKey); found {
-			w.Write(cached)
+		cached, found := s.cache.GetCachedResponse(cacheKey)
+		span.SetAttributes(attribute.Bool("wasio.cache_hit", found))
+		if found {
+			// Header blocks are stored with the body and applied again on every hit
+			out, _ := route.splitOutput(cached)
+			out.write(w)
 			return
 		}
 	}
 
 	payload := RequestPayload{
-		Params: map[string]string{},
-		Seed:   time.Now().UnixNano(),
+		Params:    map[string]string{},
+		Seed:      time.Now().UnixNano(),
+		RequestID: requestIDFrom(r.Context()),
+		Lang:      lang,
+	}
+	if route.Cookies {
+		payload.Cookies = requestCookies(r)
+	}
+	// Route defaults apply first so that query parameters can override them
+	for key, value := range route.DefaultParams {
+		payload.Params[key] = value
 	}
 	for key, values := range r.URL.Query() {
 		payload.Params[key] = values[0]
 	}
+	if uploads {
+		dir, err := os.MkdirTemp("", "wasio-upload-")
+		if err != nil {
+			s.writeError(w, r, http.StatusInternalServerError, "500 - Internal Server Error")
+			return
+		}
+		defer os.RemoveAll(dir)
+		fields, err := saveUploads(w, r, dir)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.writeError(w, r, http.StatusRequestEntityTooLarge, "413 - Request Entity Too Large")
+			} else {
+				s.writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid upload: %v", err))
+			}
+			return
+		}
+		for key, value := range fields {
+			payload.Params[key] = value
+		}
+		route.uploadDir = dir
+	}
+	delete(payload.Params, "api_key")
+	// Path segments are part of the route itself, so they take precedence over the query
+	for key, value := range pathParams {
+		payload.Params[key] = value
+	}
+
+	if route.slots != nil {
+		if !acquireSlot(r.Context(), route) {
+			w.Header().Set("Retry-After", "1")
+			s.writeError(w, r, http.StatusServiceUnavailable, "503 - Service Unavailable")
+			return
+		}
+		defer func() { <-route.slots }()
+	}
+
+	var stdin io.Reader = bytes.NewReader(serializePayload(payload))
+	if route.RawStdin {
+		stdin = http.MaxBytesReader(w, r.Body, maxRawBodySize)
+		route.logf(logDebug, "[%s] Running %s with the raw request body", payload.RequestID, route.WasmFile)
+	} else {
+		route.logf(logDebug, "[%s] Running %s with %s", payload.RequestID, route.WasmFile, redactPayload(payload))
+	}
+
+	if route.Stream {
+		s.streamInstrument(w, r, route, stdin, payload)
+		return
+	}
 
 	output := &bytes.Buffer{}
-	err := s.moduleCache.RunInstrument(route, payload, output)
+	err := s.moduleCache.RunInstrument(r.Context(), route, stdin, output)
+	if err != nil && r.Context().Err() != nil {
+		// Nobody is waiting for the response any more
+		route.logf(logNormal, "[%s] Client canceled %s", payload.RequestID, r.URL.Path)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error running module: %v", err), http.StatusInternalServerError)
+		route.logf(logNormal, "[%s] Error running %s: %v", payload.RequestID, route.WasmFile, err)
+		s.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error running module: %v", err))
 		return
 	}
 
 	response := output.Bytes()
-	if route.Cache {
-		ttl := s.config.CacheTTL
-		if route.TTL > 0 {
-			ttl = route.TTL
-		}
-		s.cache.SetCachedResponse(cacheKey, response, ttl)
+	out, err := route.splitOutput(response)
+	if err != nil {
+		route.logf(logNormal, "[%s] %s: %v", payload.RequestID, route.WasmFile, err)
+	}
+	// Responses that set cookies belong to a single client
+	if route.Cache && len(out.cookies) == 0 {
+		s.cache.SetCachedResponse(cacheKey, response, route.ttl(s.config.CacheTTL))
 	}
-	w.Write(response)
+	// Echo the seed so the exact run can be reproduced; cached responses have none
+	if route.sendsSeed() {
+		w.Header().Set("X-WASIO-Seed", strconv.FormatInt(payload.Seed, 10))
+	}
+	out.write(w)
 }
 
 // RunInstrument executes an instrument with enhanced memory management.
-func (mc *ModuleCache) RunInstrument(route Route, payload RequestPayload, output io.Writer) error {
-    compiledModule, err := mc.GetCompiledModule(route.WasmFile)
-    if err != nil {
-        return err
-    }
-    
-    ctx := context.Background()
-    moduleConfig := wazero.NewModuleConfig().
-        WithStdin(bytes.NewReader(serializePayload(payload))).
-        WithStdout(output)
-    
-    // If filesystem configuration is specified, mount the directory
-    if route.Filesystem.Mount != "" && route.Filesystem.Path != "" {
-        fsConfig := wazero.NewFSConfig().WithDirMount(route.Filesystem.Path, route.Filesystem.Mount)
-        moduleConfig = moduleConfig.WithFSConfig(fsConfig)
-    }
-    
-    mod, err := mc.rt.InstantiateModule(ctx, compiledModule, moduleConfig)
-    if err != nil {
-        return fmt.Errorf("failed to instantiate module: %v", err)
-    }
-    defer mod.Close(ctx)
-    
-    _, err = mod.ExportedFunction("_start").Call(ctx)
-    return err
-}
-
-
-// GetCompiledModule returns a cached compiled module or loads it if not present.
-func (mc *ModuleCache) GetCompiledModule(wasmFile string) (wazero.CompiledModule, error) {
-	mc.mu.RLock()
-	compiledModule, found := mc.cache[wasmFile]
-	mc.mu.RUnlock()
-	if found {
-		return compiledModule, nil
+func (mc *ModuleCache) RunInstrument(ctx context.Context, route Route, stdin io.Reader, output io.Writer) (err error) {
+	if !mc.admit() {
+		return errShuttingDown
+	}
+	defer func() {
+		mc.active.Add(-1)
+		mc.running.Done()
+	}()
+
+	compiledModule, err := mc.GetCompiledModule(ctx, route.WasmFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := tracer.Start(ctx, "RunInstrument", trace.WithAttributes(attribute.String("wasio.wasm_file", route.WasmFile)))
+	defer func() { endSpan(span, err) }()
+
+	moduleConfig := wazero.NewModuleConfig().
+		WithStartFunctions(). // _start is called below, only once
+		WithStdin(stdin).
+		WithStdout(output).
+		// wazero's default random source and clocks are deterministic fakes
+		WithRandSource(cryptorand.Reader).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithNanosleep(ctxSleep(ctx))
+	requestID := requestIDFrom(ctx)
+	if route.logs(logNormal) {
+		moduleConfig = moduleConfig.WithStderr(stderrLogger{prefix: fmt.Sprintf("[%s] %s stderr: ", requestID, route.WasmFile)})
 	}
 
-	wasmBytes, err := os.ReadFile(wasmFile)
+	// Expose configured environment variables to the instrument
+	for key, value := range route.Env {
+		moduleConfig = moduleConfig.WithEnv(key, value)
+	}
+
+	// If filesystem configuration is specified, mount the directory
+	fsConfig, mounted := wazero.NewFSConfig(), false
+	if route.Filesystem.Mount != "" && route.Filesystem.Path != "" {
+		fsConfig, mounted = fsConfig.WithDirMount(route.Filesystem.Path, route.Filesystem.Mount), true
+	}
+	// Uploaded files are mounted read-only for this request only
+	if route.uploadDir != "" {
+		fsConfig, mounted = fsConfig.WithReadOnlyDirMount(route.uploadDir, route.UploadMount), true
+	}
+	if mounted {
+		moduleConfig = moduleConfig.WithFSConfig(fsConfig)
+	}
+
+	mod, err := mc.rt.InstantiateModule(ctx, compiledModule, moduleConfig)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate module: %v", err)
+	}
+	defer mod.Close(ctx)
+
+	start := time.Now()
+	_, err = mod.ExportedFunction("_start").Call(ctx)
+	route.logf(logDebug, "[%s] %s ran in %.3fms", requestID, route.WasmFile, float64(time.Since(start).Microseconds())/1000)
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+		// A normal proc_exit(0) is not a failure
+		return nil
+	}
+	return err
+}
+
+// ctxSleep returns a nanosleep that wakes up early once ctx is done, so a
+// canceled run doesn't sit out its sleep before wazero can close it.
+func ctxSleep(ctx context.Context) sys.Nanosleep {
+	return func(ns int64) {
+		timer := time.NewTimer(time.Duration(ns))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// admit registers a new execution unless the cache is draining. Adding to
+// running under drainMu keeps it from racing with Drain's Wait.
+func (mc *ModuleCache) admit() bool {
+	mc.drainMu.Lock()
+	defer mc.drainMu.Unlock()
+	if mc.draining {
+		return false
+	}
+	mc.running.Add(1)
+	mc.active.Add(1)
+	return true
+}
+
+// Drain stops admitting executions and waits until all in-flight ones have
+// finished or ctx is done, reporting whether they all completed.
+func (mc *ModuleCache) Drain(ctx context.Context) bool {
+	mc.drainMu.Lock()
+	mc.draining = true
+	mc.drainMu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		mc.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readWasm reads a WASM binary, transparently decompressing gzip files
+// (detected by their magic bytes, e.g. *.wasm.gz).
+func readWasm(wasmFile string) ([]byte, error) {
+	data, err := os.ReadFile(wasmFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read WASM file: %v", err)
 	}
-	compiledModule, err = mc.rt.CompileModule(context.Background(), wasmBytes)
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %v", wasmFile, err)
+	}
+	defer zr.Close()
+	data, err = io.ReadAll(zr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile module: %v", err)
+		return nil, fmt.Errorf("failed to decompress %s: %v", wasmFile, err)
+	}
+	return data, nil
+}
+
+// compile compiles wasmFile for call in the background and publishes the
+// result. wazero can't interrupt a compilation, so it runs to the end even if
+// every request waiting for it has given up.
+func (mc *ModuleCache) compile(wasmFile string, call *compileCall) {
+	var module wazero.CompiledModule
+	wasmBytes, err := readWasm(wasmFile)
+	compiled := err == nil
+	if compiled {
+		if module, err = mc.compileFunc(context.Background(), wasmBytes); err != nil {
+			err = fmt.Errorf("failed to compile module: %v", err)
+		}
+	}
+
+	mc.mu.Lock()
+	delete(mc.compiling, wasmFile)
+	switch {
+	case err == nil:
+		mc.cache[wasmFile] = module
+		delete(mc.failed, wasmFile)
+	case compiled:
+		// Reading the file is cheap to retry, compiling it is not
+		mc.failed[wasmFile] = compileFailure{err, time.Now().Add(compileRetryDelay)}
+	}
+	mc.mu.Unlock()
+	call.module, call.err = module, err
+	close(call.done)
+}
+
+// GetCompiledModule returns a cached compiled module or loads it if not
+// present. Requests for a file that is being compiled wait for that
+// compilation instead of starting another, for up to the compile timeout.
+// Compilations that failed or timed out fail right away for
+// compileRetryDelay.
+func (mc *ModuleCache) GetCompiledModule(ctx context.Context, wasmFile string) (_ wazero.CompiledModule, err error) {
+	mc.mu.RLock()
+	compiledModule, found := mc.cache[wasmFile]
+	mc.mu.RUnlock()
+	if found {
+		return compiledModule, nil
 	}
 
+	ctx, span := tracer.Start(ctx, "CompileModule", trace.WithAttributes(attribute.String("wasio.wasm_file", wasmFile)))
+	defer func() { endSpan(span, err) }()
+
 	mc.mu.Lock()
-	mc.cache[wasmFile] = compiledModule
+	if failure, ok := mc.failed[wasmFile]; ok && time.Now().Before(failure.until) {
+		mc.mu.Unlock()
+		return nil, failure.err
+	}
+	call, running := mc.compiling[wasmFile]
+	if !running {
+		call = &compileCall{done: make(chan struct{})}
+		mc.compiling[wasmFile] = call
+		go mc.compile(wasmFile, call)
+	}
 	mc.mu.Unlock()
-	return compiledModule, nil
+
+	var timeout <-chan time.Time
+	if mc.compileTimeout > 0 {
+		timer := time.NewTimer(mc.compileTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-call.done:
+		return call.module, call.err
+	case <-timeout:
+		err := fmt.Errorf("failed to compile module: compilation aborted after %s", mc.compileTimeout)
+		mc.mu.Lock()
+		mc.failed[wasmFile] = compileFailure{err, time.Now().Add(compileRetryDelay)}
+		mc.mu.Unlock()
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 // serializePayload encodes payload as JSON for structured data transfer.
@@ -223,18 +994,86 @@ func serializePayload(payload RequestPayload) []byte {
 }
 
 func main() {
-	config, err := NewConfig("config.json")
+	// Later config files override earlier ones, e.g. "config.json prod.json"
+	files := os.Args[1:]
+	if len(files) == 0 {
+		files = []string{"config.json"}
+	}
+	config, err := LoadConfigs(files...)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	moduleCache := NewModuleCache()
+	moduleCache, err := NewModuleCache(config.RuntimeMode, config.MemoryLimit)
+	if err != nil {
+		log.Fatalf("Error creating runtime: %v", err)
+	}
+	moduleCache.compileTimeout = 30 * time.Second
+	if config.CompileTimeout > 0 {
+		moduleCache.compileTimeout = time.Duration(config.CompileTimeout) * time.Second
+	}
 	defer moduleCache.rt.Close(context.Background())
 	responseCache := NewResponseCache(config.CacheSize)
 
+	if config.Tracing != nil {
+		shutdownTracing, err := setupTracing(context.Background(), config.Tracing)
+		if err != nil {
+			log.Fatalf("Error setting up tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+	}
+
 	server := &Server{config: config, moduleCache: moduleCache, cache: responseCache}
-	log.Printf("Starting WASIO on port %s...", config.Port)
-	if err := http.ListenAndServe(":"+config.Port, server); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	var handler http.Handler = server
+	if base := server.basePrefix(); base != "" {
+		handler = stripBasePath(base, handler, server.writeError)
+	}
+	if config.Security != nil {
+		handler = config.Security.Middleware(handler)
+	}
+	if config.AccessLog != nil {
+		accessLog, err := openRotatingFile(config.AccessLog.File,
+			int64(config.AccessLog.MaxSizeMB)<<20, time.Duration(config.AccessLog.RotateHours)*time.Hour)
+		if err != nil {
+			log.Fatalf("Error opening access log: %v", err)
+		}
+		defer accessLog.Close()
+		handler = withAccessLog(accessLog, handler)
+	}
+	handler = withRequestID(handler)
+	if config.Tracing != nil {
+		handler = withTracing(handler)
+	}
+
+	httpSrv := &http.Server{Addr: ":" + config.Port, Handler: handler}
+	go func() {
+		log.Printf("Starting WASIO on port %s...", config.Port)
+		if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go server.warmUp(ctx)
+	<-ctx.Done()
+	server.ready.Store(false)
+
+	timeout := 30 * time.Second
+	if config.ShutdownTimeout > 0 {
+		timeout = time.Duration(config.ShutdownTimeout) * time.Second
+	}
+	pending := moduleCache.active.Load()
+	log.Printf("Shutting down, waiting up to %s for %d in-flight executions...", timeout, pending)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown incomplete: %v", err)
+	}
+	// Handlers may have given up on their executions; wait for those too
+	if moduleCache.Drain(shutdownCtx) {
+		log.Printf("Drained %d in-flight executions", pending)
+	} else {
+		log.Printf("Shutdown timeout reached with %d executions still running", moduleCache.active.Load())
 	}
 }
@@ -0,0 +1,914 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Test instruments built from testdata/echo by TestMain
+var (
+	echoWasm string
+	altWasm  string
+)
+
+// testModules is shared by the tests so each module is compiled only once.
+var testModules *ModuleCache
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "wasio-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	echoWasm, altWasm = filepath.Join(dir, "echo.wasm"), filepath.Join(dir, "alt.wasm")
+	for file, name := range map[string]string{echoWasm: "echo", altWasm: "alt"} {
+		cmd := exec.Command("go", "build", "-o", file, "-ldflags", "-X main.name="+name, "./testdata/echo")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "building %s: %v\n%s", file, err, out)
+			return 1
+		}
+	}
+
+	testModules, err = NewModuleCache("", 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer testModules.rt.Close(context.Background())
+	return m.Run()
+}
+
+// newTestServer loads config as a config file and returns a server for it.
+// {echo} and {alt} in config stand for the paths of the test instruments.
+func newTestServer(t *testing.T, config string) *Server {
+	t.Helper()
+	config = strings.NewReplacer("{echo}", echoWasm, "{alt}", altWasm).Replace(config)
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfigs(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{config: cfg, moduleCache: testModules, cache: NewResponseCache(cfg.CacheSize)}
+}
+
+// serve sends a request for target through h and returns the response.
+func serve(h http.Handler, method, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// echoReply is what the echo instrument prints for a JSON payload.
+type echoReply struct {
+	Name    string         `json:"name"`
+	Payload RequestPayload `json:"payload"`
+}
+
+// decodeEcho decodes the echo instrument's reply from a response.
+func decodeEcho(t *testing.T, w *httptest.ResponseRecorder) echoReply {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", w.Code, w.Body)
+	}
+	var reply echoReply
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+	return reply
+}
+
+func TestLoadConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, config string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	base := write("base.json", `{
+		"port": "8080",
+		"cache_ttl": 60,
+		"basic_auth": {"username": "admin", "password_hash": "hash", "realm": "base"},
+		"static": {"prefix": "/static/", "dir": "public"},
+		"routes": {
+			"/a": {"wasm_file": "a.wasm", "cache": true, "default_params": {"x": "1"}},
+			"/b": {"wasm_file": "b.wasm"}
+		}
+	}`)
+	prod := write("prod.json", `{
+		"port": "80",
+		"basic_auth": {"realm": "prod"},
+		"static": {"dir": "/srv/public"},
+		"routes": {
+			"/a": {"wasm_file": "a-prod.wasm"},
+			"/c": {"wasm_file": "c.wasm", "max_concurrent": 2}
+		}
+	}`)
+
+	cfg, err := LoadConfigs(base, prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "80" || cfg.CacheTTL != 60 {
+		t.Errorf("port %q, cache_ttl %d: want 80 from prod and 60 from base", cfg.Port, cfg.CacheTTL)
+	}
+	if want := (BasicAuth{Username: "admin", PasswordHash: "hash", Realm: "prod"}); cfg.BasicAuth == nil || *cfg.BasicAuth != want {
+		t.Errorf("basic_auth = %+v, want %+v", cfg.BasicAuth, want)
+	}
+	if cfg.Static.Prefix != "/static/" || cfg.Static.Dir != "/srv/public" {
+		t.Errorf("static = %+v, want the prefix from base and the dir from prod", cfg.Static)
+	}
+
+	var paths []string
+	for path := range cfg.Routes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if fmt.Sprint(paths) != "[/a /b /c]" {
+		t.Errorf("routes %v, want the union of both files", paths)
+	}
+	// A redefined route replaces the earlier one as a whole
+	if a := cfg.Routes["/a"]; a.WasmFile != "a-prod.wasm" || a.Cache || a.DefaultParams != nil {
+		t.Errorf("/a = %+v, want only the prod definition", a)
+	}
+	if cfg.Routes["/b"].WasmFile != "b.wasm" {
+		t.Errorf("/b = %+v, want it kept from base", cfg.Routes["/b"])
+	}
+	if c := cfg.Routes["/c"]; cap(c.slots) != 2 {
+		t.Errorf("/c has %d slots, want 2", cap(c.slots))
+	}
+
+	// Without the override, base applies unchanged
+	cfg, err = LoadConfigs(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "8080" || cfg.BasicAuth.Realm != "base" || cfg.Routes["/a"].WasmFile != "a.wasm" {
+		t.Errorf("base alone: %+v", cfg)
+	}
+
+	broken := write("broken.json", `{"port": `)
+	if _, err := LoadConfigs(base, broken); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("broken file: got %v, want an error naming it", err)
+	}
+	if _, err := LoadConfigs(base, filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("missing file accepted")
+	}
+}
+
+func TestDefaultParams(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {
+		"wasm_file": "{echo}",
+		"default_params": {"max_iter": "100", "zoom": "1"}
+	}}}`)
+
+	params := decodeEcho(t, serve(s, "GET", "/echo")).Payload.Params
+	if params["max_iter"] != "100" || params["zoom"] != "1" {
+		t.Errorf("params = %v, want the defaults", params)
+	}
+
+	params = decodeEcho(t, serve(s, "GET", "/echo?zoom=4&x=1")).Payload.Params
+	if params["max_iter"] != "100" || params["zoom"] != "4" || params["x"] != "1" {
+		t.Errorf("params = %v, want zoom overridden by the query", params)
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/calc/:op/:a/:b": {"wasm_file": "{echo}", "default_params": {"op": "sub"}},
+		"/calc/add/1/2": {"wasm_file": "{alt}"}
+	}}`)
+
+	params := decodeEcho(t, serve(s, "GET", "/calc/add/5/3?a=9&x=1")).Payload.Params
+	want := map[string]string{"op": "add", "a": "5", "b": "3", "x": "1"}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("params[%q] = %q, want %q", key, params[key], value)
+		}
+	}
+
+	if name := decodeEcho(t, serve(s, "GET", "/calc/add/1/2")).Name; name != "alt" {
+		t.Errorf("/calc/add/1/2 ran %s, want the exact route", name)
+	}
+
+	for _, target := range []string{"/calc/add/5", "/calc/add/5/3/1", "/calc/add//3"} {
+		if w := serve(s, "GET", target); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", target, w.Code)
+		}
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {
+		"wasm_file": "{echo}",
+		"alternatives": {"application/json": "{alt}"}
+	}}}`)
+
+	tests := []struct{ accept, want string }{
+		{"", "echo"},
+		{"text/html", "echo"},
+		{"application/json", "alt"},
+		{"text/html, application/json;q=0.5", "alt"},
+		{"application/json;q=0", "echo"},
+	}
+	for _, tt := range tests {
+		w := serve(s, "GET", "/echo", "Accept", tt.accept)
+		if name := decodeEcho(t, w).Name; name != tt.want {
+			t.Errorf("Accept %q ran %s, want %s", tt.accept, name, tt.want)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("Accept %q: Vary = %q, want Accept", tt.accept, vary)
+		}
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {"wasm_file": "{echo}", "api_keys": ["k1", "k2"]}}}`)
+
+	tests := []struct {
+		name   string
+		target string
+		header []string
+		want   int
+	}{
+		{"header", "/echo", []string{"X-API-Key", "k2"}, http.StatusOK},
+		{"param", "/echo?api_key=k1", nil, http.StatusOK},
+		{"missing", "/echo", nil, http.StatusUnauthorized},
+		{"wrong", "/echo", []string{"X-API-Key", "k3"}, http.StatusUnauthorized},
+		{"prefix", "/echo?api_key=k", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := serve(s, "GET", tt.target, tt.header...); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	// The key is the client's secret, not a parameter for the instrument
+	params := decodeEcho(t, serve(s, "GET", "/echo?api_key=k1&x=1")).Payload.Params
+	if _, ok := params["api_key"]; ok || params["x"] != "1" {
+		t.Errorf("params = %v, want x without api_key", params)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		mode, target string
+		want         int
+		location     string
+	}{
+		{"", "/wiki", http.StatusOK, ""},
+		{"", "/wiki/", http.StatusNotFound, ""},
+		{"redirect", "/wiki/", http.StatusMovedPermanently, "/base/wiki"},
+		{"redirect", "/wiki/?page=home", http.StatusMovedPermanently, "/base/wiki?page=home"},
+		{"redirect", "/docs", http.StatusMovedPermanently, "/base/docs/"},
+		{"ignore", "/wiki/", http.StatusOK, ""},
+		{"ignore", "/docs", http.StatusOK, ""},
+		{"ignore", "/missing/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t, `{"base_path": "/base", "trailing_slash": "`+tt.mode+`", "routes": {
+			"/wiki": {"wasm_file": "{echo}"},
+			"/docs/": {"wasm_file": "{echo}"}
+		}}`)
+		w := serve(s, "GET", tt.target)
+		if w.Code != tt.want || w.Header().Get("Location") != tt.location {
+			t.Errorf("%q %s: got %d Location %q, want %d Location %q",
+				tt.mode, tt.target, w.Code, w.Header().Get("Location"), tt.want, tt.location)
+		}
+	}
+}
+
+func TestPickVariantDistribution(t *testing.T) {
+	variants := []Variant{{"a.wasm", 90}, {"b.wasm", 10}, {"off.wasm", 0}}
+	const n = 20000
+	counts := make([]int, len(variants))
+	for i := 0; i < n; i++ {
+		counts[pickVariant(variants)]++
+	}
+	if counts[2] != 0 {
+		t.Errorf("zero-weight variant picked %d times", counts[2])
+	}
+	// Allow four standard deviations, sqrt(n*0.1*0.9) = 42, around 10%
+	if share := counts[1]; share < n/10-170 || share > n/10+170 {
+		t.Errorf("10%% variant picked %d of %d times", share, n)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/echo": {"wasm_file": "{echo}", "variants": [{"wasm_file": "{echo}", "weight": 1}, {"wasm_file": "{alt}", "weight": 1}]},
+		"/sticky": {"wasm_file": "{echo}", "sticky_variants": true, "variants": [{"wasm_file": "{echo}", "weight": 1}, {"wasm_file": "{alt}", "weight": 1}]}
+	}}`)
+
+	seen := map[string]bool{}
+	for i := 0; i < 40 && len(seen) < 2; i++ {
+		seen[decodeEcho(t, serve(s, "GET", "/echo")).Name] = true
+	}
+	if !seen["echo"] || !seen["alt"] {
+		t.Errorf("variants served: %v, want both", seen)
+	}
+
+	w := serve(s, "GET", "/sticky")
+	first := decodeEcho(t, w).Name
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != variantCookie {
+		t.Fatalf("cookies = %v, want %s", cookies, variantCookie)
+	}
+	for i := 0; i < 10; i++ {
+		w := serve(s, "GET", "/sticky", "Cookie", cookies[0].String())
+		if name := decodeEcho(t, w).Name; name != first {
+			t.Fatalf("sticky client moved from %s to %s", first, name)
+		}
+	}
+}
+
+func TestGzipModule(t *testing.T) {
+	wasm, err := os.ReadFile(echoWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(wasm)
+	zw.Close()
+	dir := t.TempDir()
+	good, corrupt := filepath.Join(dir, "echo.wasm.gz"), filepath.Join(dir, "corrupt.wasm.gz")
+	if err := os.WriteFile(good, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(corrupt, buf.Bytes()[:buf.Len()/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, fmt.Sprintf(`{"routes": {"/good": {"wasm_file": %q}, "/corrupt": {"wasm_file": %q}}}`, good, corrupt))
+	if name := decodeEcho(t, serve(s, "GET", "/good")).Name; name != "echo" {
+		t.Errorf("gzipped module ran %s", name)
+	}
+	w := serve(s, "GET", "/corrupt")
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "failed to decompress") {
+		t.Errorf("corrupt module: got %d %q, want a decompression error", w.Code, w.Body)
+	}
+}
+
+func TestRuntimeModes(t *testing.T) {
+	payload := serializePayload(RequestPayload{Params: map[string]string{"x": "1"}, Seed: 42})
+	var outputs []string
+	for _, mode := range []string{"compiler", "interpreter"} {
+		mc, err := NewModuleCache(mode, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		var out bytes.Buffer
+		err = mc.RunInstrument(context.Background(), Route{WasmFile: echoWasm}, bytes.NewReader(payload), &out)
+		mc.rt.Close(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		outputs = append(outputs, out.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("compiler output %q differs from interpreter output %q", outputs[0], outputs[1])
+	}
+
+	if _, err := NewModuleCache("jit", 0); err == nil {
+		t.Error("unknown runtime mode accepted")
+	}
+}
+
+func TestCompileTimeout(t *testing.T) {
+	mc, err := NewModuleCache("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.rt.Close(context.Background())
+	mc.compileTimeout = 50 * time.Millisecond
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	compileFunc := mc.compileFunc
+	mc.compileFunc = func(ctx context.Context, wasm []byte) (wazero.CompiledModule, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return compileFunc(ctx, wasm)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = mc.GetCompiledModule(context.Background(), echoWasm)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil || !strings.Contains(err.Error(), "compilation aborted") {
+			t.Errorf("slow compile: got %v, want a timeout", err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := mc.GetCompiledModule(context.Background(), echoWasm); err == nil {
+		t.Error("timed-out module compiled by a later request")
+	}
+	if elapsed := time.Since(start); elapsed >= mc.compileTimeout {
+		t.Errorf("remembered failure took %s", elapsed)
+	}
+	mu.Lock()
+	if calls != 1 {
+		t.Errorf("compiled %d times, want 1", calls)
+	}
+	mu.Unlock()
+
+	// The background compilation still completes and is used from then on
+	mc.mu.RLock()
+	call := mc.compiling[echoWasm]
+	mc.mu.RUnlock()
+	close(release)
+	<-call.done
+	if _, err := mc.GetCompiledModule(context.Background(), echoWasm); err != nil {
+		t.Errorf("after the background compilation: %v", err)
+	}
+}
+
+func TestCompileFailure(t *testing.T) {
+	mc, err := NewModuleCache("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.rt.Close(context.Background())
+	calls := 0
+	mc.compileFunc = func(context.Context, []byte) (wazero.CompiledModule, error) {
+		calls++
+		return nil, fmt.Errorf("invalid module")
+	}
+
+	for range 3 {
+		if _, err := mc.GetCompiledModule(context.Background(), echoWasm); err == nil || !strings.Contains(err.Error(), "invalid module") {
+			t.Errorf("got %v, want the compile error", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("compiled %d times, want 1", calls)
+	}
+
+	mc.failed[echoWasm] = compileFailure{fmt.Errorf("invalid module"), time.Now()}
+	mc.GetCompiledModule(context.Background(), echoWasm)
+	if calls != 2 {
+		t.Errorf("expired failure not retried: compiled %d times", calls)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	mc, err := NewModuleCache("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.rt.Close(context.Background())
+	route := Route{WasmFile: echoWasm}
+	if _, err := mc.GetCompiledModule(context.Background(), echoWasm); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- mc.RunInstrument(context.Background(), route, bytes.NewReader(serializePayload(RequestPayload{Params: map[string]string{"sleep": "300"}})), &out)
+	}()
+	for mc.active.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if mc.Drain(ctx) {
+		t.Error("drain reported a sleeping execution as finished")
+	}
+	if err := mc.RunInstrument(context.Background(), route, bytes.NewReader(serializePayload(RequestPayload{})), io.Discard); err != errShuttingDown {
+		t.Errorf("execution started while draining: got %v, want %v", err, errShuttingDown)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !mc.Drain(ctx) {
+		t.Fatal("drain timed out")
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("drained execution failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drained execution never returned")
+	}
+	if !strings.Contains(out.String(), `"sleep":"300"`) {
+		t.Errorf("drained execution wrote %q", out.String())
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	route := Route{WasmFile: echoWasm}
+	if _, err := testModules.GetCompiledModule(context.Background(), echoWasm); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := testModules.RunInstrument(ctx, route, bytes.NewReader(serializePayload(RequestPayload{Params: map[string]string{"sleep": "5000"}})), io.Discard)
+	if err == nil {
+		t.Error("canceled run succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("canceled run returned after %s", elapsed)
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	s := newTestServer(t, `{"cache_ttl": 60, "routes": {
+		"/echo": {"wasm_file": "{echo}", "cache": true},
+		"/stream": {"wasm_file": "{echo}", "stream": true}
+	}}`)
+
+	for i, target := range []string{"/echo?out=hello", "/echo?out=hello"} {
+		w := serve(s, "GET", target)
+		if got := w.Header().Get("Content-Length"); got != "5" || w.Body.String() != "hello" {
+			t.Errorf("request %d: Content-Length %q for body %q, want 5", i, got, w.Body)
+		}
+	}
+	w := serve(s, "GET", "/stream?out=hello")
+	if got := w.Header().Get("Content-Length"); got != "" || w.Body.String() != "hello" {
+		t.Errorf("streamed: Content-Length %q for body %q, want none", got, w.Body)
+	}
+}
+
+func TestRawStdin(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/raw": {"wasm_file": "{echo}", "raw_stdin": true, "cache": true}}}`)
+
+	// The second request would get the first body back if raw responses were cached
+	for _, body := range [][]byte{{0x89, 'P', 'N', 'G', 0, 1, 2, 0xff}, []byte("second body")} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/raw?x=1", bytes.NewReader(body)))
+		if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), body) {
+			t.Errorf("got %d %q, want the body %q echoed", w.Code, w.Body, body)
+		}
+		// raw_stdin instruments get no payload, so there is no seed to report
+		if seed := w.Header().Get("X-WASIO-Seed"); seed != "" {
+			t.Errorf("X-WASIO-Seed = %q, want none", seed)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/echo": {"wasm_file": "{echo}"},
+		"/secret": {"wasm_file": "{echo}", "hide_seed": true}
+	}}`)
+
+	w := serve(s, "GET", "/echo")
+	seed := decodeEcho(t, w).Payload.Seed
+	if seed <= 0 {
+		t.Errorf("seed = %d, want a positive value", seed)
+	}
+	if header := w.Header().Get("X-WASIO-Seed"); header != strconv.FormatInt(seed, 10) {
+		t.Errorf("X-WASIO-Seed = %q, want the payload's seed %d", header, seed)
+	}
+
+	w = serve(s, "GET", "/secret")
+	if decodeEcho(t, w).Payload.Seed <= 0 {
+		t.Error("hide_seed route got no seed")
+	}
+	if header := w.Header().Get("X-WASIO-Seed"); header != "" {
+		t.Errorf("hide_seed route sent X-WASIO-Seed %q", header)
+	}
+}
+
+func TestInstrumentClock(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {"wasm_file": "{echo}"}}}`)
+
+	w := serve(s, "GET", "/echo?clock=1")
+	now, err := strconv.ParseInt(w.Body.String(), 10, 64)
+	if err != nil || now < time.Now().Unix()-60 || now > time.Now().Unix()+60 {
+		t.Errorf("instrument clock reads %q, want the current time", w.Body)
+	}
+
+	start := time.Now()
+	serve(s, "GET", "/echo?sleep=200")
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("sleeping 200ms took %s", elapsed)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	s := newTestServer(t, `{"routes": {
+		"/queued": {"wasm_file": "{echo}", "max_concurrent": 1, "queue_timeout_ms": 5000},
+		"/short": {"wasm_file": "{echo}", "max_concurrent": 1, "queue_timeout_ms": 50},
+		"/none": {"wasm_file": "{echo}", "max_concurrent": 1}
+	}}`)
+	// Compile ahead so the first run doesn't eat into the timeouts
+	decodeEcho(t, serve(s, "GET", "/queued"))
+
+	tests := []struct {
+		path string
+		want []int
+	}{
+		{"/queued", []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+		{"/short", []int{http.StatusOK, http.StatusServiceUnavailable}},
+		{"/none", []int{http.StatusOK, http.StatusServiceUnavailable}},
+	}
+	for _, tt := range tests {
+		codes := make([]int, len(tt.want))
+		var wg sync.WaitGroup
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := serve(s, "GET", tt.path+"?sleep=300")
+				if w.Code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+					t.Errorf("%s: 503 without Retry-After", tt.path)
+				}
+				codes[i] = w.Code
+			}()
+			// Keep the arrival order deterministic
+			time.Sleep(20 * time.Millisecond)
+		}
+		wg.Wait()
+		sort.Ints(codes)
+		if fmt.Sprint(codes) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: statuses %v, want %v", tt.path, codes, tt.want)
+		}
+	}
+}
+
+func TestRouteTTL(t *testing.T) {
+	zero, positive := 0, 600
+	tests := []struct {
+		name string
+		ttl  *int
+		want int
+	}{
+		{"nil", nil, 300},
+		{"zero", &zero, 0},
+		{"positive", &positive, 600},
+	}
+	for _, tt := range tests {
+		if got := (Route{TTL: tt.ttl}).ttl(300); got != tt.want {
+			t.Errorf("%s: ttl = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCaching(t *testing.T) {
+	s := newTestServer(t, `{"cache_ttl": 300, "routes": {
+		"/inherit": {"wasm_file": "{echo}", "cache": true},
+		"/zero": {"wasm_file": "{echo}", "cache": true, "ttl": 0},
+		"/positive": {"wasm_file": "{echo}", "cache": true, "ttl": 600},
+		"/off": {"wasm_file": "{echo}", "ttl": 600}
+	}}`)
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/inherit", true},
+		{"/zero", false},
+		{"/positive", true},
+		{"/off", false},
+	}
+	for _, tt := range tests {
+		first := decodeEcho(t, serve(s, "GET", tt.path))
+		w := serve(s, "GET", tt.path)
+		second := decodeEcho(t, w)
+		// Each run gets a new seed, so an unchanged one means the cache answered
+		if cached := first.Payload.Seed == second.Payload.Seed; cached != tt.cached {
+			t.Errorf("%s: served from cache = %v, want %v", tt.path, cached, tt.cached)
+		}
+		if seed := w.Header().Get("X-WASIO-Seed"); tt.cached == (seed != "") {
+			t.Errorf("%s: X-WASIO-Seed = %q on the second request", tt.path, seed)
+		}
+		if other := decodeEcho(t, serve(s, "GET", tt.path+"?x=1")); other.Payload.Seed == second.Payload.Seed {
+			t.Errorf("%s: a different query was served from the cache", tt.path)
+		}
+	}
+}
+
+func TestResponseCacheExpiry(t *testing.T) {
+	rc := NewResponseCache(10)
+	rc.SetCachedResponse("zero", []byte("a"), 0)
+	if _, found := rc.GetCachedResponse("zero"); found {
+		t.Error("TTL 0 response was cached")
+	}
+	rc.SetCachedResponse("live", []byte("b"), 60)
+	if value, found := rc.GetCachedResponse("live"); !found || string(value) != "b" {
+		t.Errorf("live entry = %q, %v", value, found)
+	}
+	rc.data["old"] = CachedResponse{Value: []byte("c"), Expiration: time.Now().Add(-time.Second)}
+	if _, found := rc.GetCachedResponse("old"); found {
+		t.Error("expired response was served")
+	}
+}
+
+func TestCacheSafeMethods(t *testing.T) {
+	s := newTestServer(t, `{"cache_ttl": 300, "routes": {"/echo": {"wasm_file": "{echo}", "cache": true}}}`)
+	seed := func(method string) int64 {
+		t.Helper()
+		return decodeEcho(t, serve(s, method, "/echo")).Payload.Seed
+	}
+
+	// A POST neither populates the cache nor is answered from it
+	post := seed("POST")
+	get := seed("GET")
+	if get == post {
+		t.Error("GET was answered with the POST's response")
+	}
+	if seed("GET") != get {
+		t.Error("second GET was not served from the cache")
+	}
+	if seed("POST") == get || seed("DELETE") == get || seed("PUT") == get {
+		t.Error("unsafe method was served from the cache")
+	}
+	if w := serve(s, "HEAD", "/echo"); w.Header().Get("X-WASIO-Seed") != "" {
+		t.Error("HEAD was not served from the cache")
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "404.html")
+	if err := os.WriteFile(page, []byte("<h1>Nothing here</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, fmt.Sprintf(`{
+		"error_pages": {"404": %q, "401": %q},
+		"routes": {"/echo": {"wasm_file": "{echo}", "api_keys": ["key"]}}
+	}`, page, filepath.Join(filepath.Dir(page), "missing.html")))
+
+	w := serve(s, "GET", "/nowhere")
+	if w.Code != http.StatusNotFound || w.Body.String() != "<h1>Nothing here</h1>" || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("custom 404: got %d %q (%s)", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+
+	// An unreadable page, or none at all, falls back to plain text
+	w = serve(s, "GET", "/echo")
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || strings.TrimSpace(w.Body.String()) != "401 - Unauthorized" {
+		t.Errorf("missing 401 page: got %d %q (%s)", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+	s.config.ErrorPages = nil
+	w = serve(s, "GET", "/nowhere")
+	if w.Code != http.StatusNotFound || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") || strings.TrimSpace(w.Body.String()) != "404 - Not Found" {
+		t.Errorf("plain 404: got %d %q (%s)", w.Code, w.Body, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestFavicon(t *testing.T) {
+	check := func(name string, s *Server, wantType string, want []byte) {
+		t.Helper()
+		w := serve(s, "GET", "/favicon.ico")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != wantType || !bytes.Equal(w.Body.Bytes(), want) {
+			t.Errorf("%s: got %d, %s, %d bytes; want 200, %s, %d bytes", name, w.Code, w.Header().Get("Content-Type"), w.Body.Len(), wantType, len(want))
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=604800" {
+			t.Errorf("%s: Cache-Control = %q", name, cc)
+		}
+	}
+	if len(defaultFavicon) == 0 {
+		t.Fatal("no embedded favicon")
+	}
+	check("default", newTestServer(t, `{}`), "image/x-icon", defaultFavicon)
+
+	icon := filepath.Join(t.TempDir(), "icon.png")
+	if err := os.WriteFile(icon, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	check("configured", newTestServer(t, fmt.Sprintf(`{"favicon": %q}`, icon)), "image/png", []byte("png"))
+	check("missing", newTestServer(t, `{"favicon": "/nonexistent/icon.png"}`), "image/x-icon", defaultFavicon)
+
+	s := newTestServer(t, `{"routes": {"/favicon.ico": {"wasm_file": "{echo}"}}}`)
+	if name := decodeEcho(t, serve(s, "GET", "/favicon.ico")).Name; name != "echo" {
+		t.Errorf("route for /favicon.ico ran %q", name)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"style.css":         "body {}",
+		"docs/index.html":   "<h1>Docs</h1>",
+		"assets/logo.txt":   "logo",
+		"assets/secret.txt": "listed",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, fmt.Sprintf(`{
+		"static": {"prefix": "/static/", "dir": %q},
+		"routes": {"/static/api": {"wasm_file": "{echo}"}}
+	}`, dir))
+
+	tests := []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/static/style.css", http.StatusOK, "body {}"},
+		{"/static/assets/logo.txt", http.StatusOK, "logo"},
+		{"/static/docs/", http.StatusOK, "<h1>Docs</h1>"},
+		{"/static/missing.css", http.StatusNotFound, ""},
+		// Directories without an index.html are not listed
+		{"/static/assets/", http.StatusNotFound, ""},
+		{"/static/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := serve(s, "GET", tt.target)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s: got %d %q, want %d %q", tt.target, w.Code, w.Body, tt.code, tt.body)
+		}
+		if strings.Contains(w.Body.String(), "secret.txt") {
+			t.Errorf("%s: directory listed", tt.target)
+		}
+	}
+	if ct := serve(s, "GET", "/static/style.css").Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("style.css served as %q", ct)
+	}
+
+	// Routes win over the static prefix
+	if name := decodeEcho(t, serve(s, "GET", "/static/api")).Name; name != "echo" {
+		t.Errorf("/static/api ran %q", name)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", ""},
+		{"de", "de"},
+		{"de-DE,de;q=0.9,en;q=0.8", "de-DE"},
+		{"en;q=0.5, fr", "fr"},
+		{"en; q=0.7, de;q=0.8", "de"},
+		{"*, de;q=0.1", "de"},
+		{"en;q=0", ""},
+		{"en;q=0.4, de;q=bogus", "de"},
+		{"fr;q=0.6, it;q=0.6", "fr"},
+	}
+	for _, tt := range tests {
+		if got := preferredLanguage(tt.header); got != tt.want {
+			t.Errorf("preferredLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	s := newTestServer(t, `{"cache_ttl": 60, "routes": {"/echo": {"wasm_file": "{echo}", "cache": true}}}`)
+
+	de := serve(s, "GET", "/echo", "Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	if lang := decodeEcho(t, de).Payload.Lang; lang != "de-DE" {
+		t.Errorf("payload lang = %q, want de-DE", lang)
+	}
+	en := serve(s, "GET", "/echo", "Accept-Language", "en")
+	if lang := decodeEcho(t, en).Payload.Lang; lang != "en" {
+		t.Errorf("cached response for another language served: lang = %q, want en", lang)
+	}
+	if again := serve(s, "GET", "/echo", "Accept-Language", "de-DE"); again.Body.String() != de.Body.String() {
+		t.Errorf("second de-DE request not served from its cache entry: %q", again.Body)
+	}
+}
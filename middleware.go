@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicAuth holds HTTP Basic Auth credentials. The password is stored as a bcrypt hash.
+type BasicAuth struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Realm        string `json:"realm"`
+}
+
+// Check reports whether the request carries valid credentials.
+func (ba *BasicAuth) Check(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// Always run bcrypt so a wrong username takes as long as a wrong password
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(ba.Username)) == 1
+	passOK := bcrypt.CompareHashAndPassword([]byte(ba.PasswordHash), []byte(pass)) == nil
+	return userOK && passOK
+}
+
+// errorWriter sends an error response, as Server.writeError does, so
+// middleware errors honor JSON clients and the configured error pages.
+type errorWriter func(w http.ResponseWriter, r *http.Request, status int, message string)
+
+// Challenge responds with 401 and a WWW-Authenticate header for the realm.
+func (ba *BasicAuth) Challenge(w http.ResponseWriter, r *http.Request, writeError errorWriter) {
+	realm := ba.Realm
+	if realm == "" {
+		realm = "WASIO"
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm))
+	writeError(w, r, http.StatusUnauthorized, "401 - Unauthorized")
+}
+
+// stripBasePath removes base (of the form "/prefix") from the request path
+// before calling next, so the server can run behind a proxy under a sub-path.
+// Requests outside base get 404.
+func stripBasePath(base string, next http.Handler, writeError errorWriter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, base)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			writeError(w, r, http.StatusNotFound, "404 - Not Found")
+			return
+		}
+		if rest == "" {
+			rest = "/"
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// requestIDKey is the context key for the request ID.
+type requestIDKey struct{}
+
+// requestIDFrom returns the request ID stored by withRequestID, if any.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a client-supplied ID is short and printable
+// enough to be echoed in headers and logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID tags each request with an ID, honoring a valid incoming
+// X-Request-ID, and echoes it in the response header.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			var b [16]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// statusRecorder captures the status code and body size written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// WriteHeader records status before passing it on.
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the body bytes written.
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// orDash returns s, or "-" for an empty log field.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// withAccessLog writes one Combined Log Format line per request to out,
+// followed by the request ID and the time taken.
+func withAccessLog(out io.Writer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		user, _, _ := r.BasicAuth()
+		fmt.Fprintf(out, "%s - %s [%s] %q %d %d %q %q %s %.3fms\n",
+			host, orDash(user), start.Format("02/Jan/2006:15:04:05 -0700"),
+			r.Method+" "+r.URL.RequestURI()+" "+r.Proto, rec.status, rec.size,
+			orDash(r.Referer()), orDash(r.UserAgent()), orDash(w.Header().Get("X-Request-ID")),
+			float64(time.Since(start).Microseconds())/1000)
+	})
+}
+
+// defaultCSP restricts content to the server itself, plus jsDelivr for pages
+// that load libraries from the CDN.
+const defaultCSP = "default-src 'self'; script-src 'self' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; img-src 'self' data:"
+
+// SecurityHeaders configures headers hardening every response. Empty fields use safe defaults.
+type SecurityHeaders struct {
+	FrameOptions          string `json:"frame_options"`
+	ReferrerPolicy        string `json:"referrer_policy"`
+	ContentSecurityPolicy string `json:"content_security_policy"`
+}
+
+// Middleware adds the security headers to every response from next.
+func (sh *SecurityHeaders) Middleware(next http.Handler) http.Handler {
+	frame, referrer, csp := sh.FrameOptions, sh.ReferrerPolicy, sh.ContentSecurityPolicy
+	if frame == "" {
+		frame = "DENY"
+	}
+	if referrer == "" {
+		referrer = "strict-origin-when-cross-origin"
+	}
+	if csp == "" {
+		csp = defaultCSP
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", frame)
+		h.Set("Referrer-Policy", referrer)
+		h.Set("Content-Security-Policy", csp)
+		next.ServeHTTP(w, r)
+	})
+}
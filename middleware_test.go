@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// basicAuthConfig returns a basic_auth config object for admin:secret.
+func basicAuthConfig(t *testing.T) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf(`{"username": "admin", "password_hash": %q, "realm": "Test"}`, hash)
+}
+
+func TestBasicAuth(t *testing.T) {
+	auth := basicAuthConfig(t)
+	s := newTestServer(t, `{
+		"basic_auth": `+auth+`,
+		"routes": {"/echo": {"wasm_file": "{echo}"}}
+	}`)
+	route := newTestServer(t, `{"routes": {
+		"/echo": {"wasm_file": "{echo}", "basic_auth": `+auth+`},
+		"/open": {"wasm_file": "{echo}"}
+	}}`)
+
+	tests := []struct {
+		name       string
+		s          *Server
+		target     string
+		user, pass string
+		want       int
+	}{
+		{"server, valid", s, "/echo", "admin", "secret", http.StatusOK},
+		{"server, wrong password", s, "/echo", "admin", "nope", http.StatusUnauthorized},
+		{"server, wrong user", s, "/echo", "root", "secret", http.StatusUnauthorized},
+		{"server, none", s, "/echo", "", "", http.StatusUnauthorized},
+		{"server, unknown path", s, "/missing", "", "", http.StatusUnauthorized},
+		{"route, valid", route, "/echo", "admin", "secret", http.StatusOK},
+		{"route, none", route, "/echo", "", "", http.StatusUnauthorized},
+		{"route, other route", route, "/open", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, tt.pass)
+		}
+		w := httptest.NewRecorder()
+		tt.s.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+		challenge := w.Header().Get("WWW-Authenticate")
+		if tt.want == http.StatusUnauthorized && challenge != `Basic realm="Test", charset="UTF-8"` {
+			t.Errorf("%s: WWW-Authenticate = %q", tt.name, challenge)
+		}
+		if tt.want == http.StatusOK && challenge != "" {
+			t.Errorf("%s: unexpected challenge %q", tt.name, challenge)
+		}
+	}
+}
+
+func TestBasicAuthJSONError(t *testing.T) {
+	s := newTestServer(t, `{"basic_auth": `+basicAuthConfig(t)+`, "routes": {}}`)
+
+	w := serve(s, "GET", "/echo", "Accept", "application/json")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"status":401`) {
+		t.Errorf("got %d %q, want a JSON 401", w.Code, w.Body)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("JSON error has no WWW-Authenticate header")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/echo": {"wasm_file": "{echo}"}}}`)
+	var log strings.Builder
+	h := withRequestID(withAccessLog(&log, s))
+
+	tests := []struct {
+		name, sent string
+		generated  bool
+	}{
+		{"generated", "", true},
+		{"client", "abc-123", false},
+		{"too long", strings.Repeat("a", 129), true},
+		{"unprintable", "a b", true},
+	}
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		log.Reset()
+		w := serve(h, "GET", "/echo", "X-Request-ID", tt.sent)
+		id := w.Header().Get("X-Request-ID")
+		switch {
+		case tt.generated && (len(id) != 32 || seen[id]):
+			t.Errorf("%s: X-Request-ID = %q, want a new 32-digit ID", tt.name, id)
+		case !tt.generated && id != tt.sent:
+			t.Errorf("%s: X-Request-ID = %q, want %q", tt.name, id, tt.sent)
+		}
+		seen[id] = true
+		if got := decodeEcho(t, w).Payload.RequestID; got != id {
+			t.Errorf("%s: payload request_id = %q, want %q", tt.name, got, id)
+		}
+		if !strings.Contains(log.String(), " "+id+" ") {
+			t.Errorf("%s: access log %q lacks %q", tt.name, log.String(), id)
+		}
+	}
+}
+
+func TestStripBasePath(t *testing.T) {
+	s := newTestServer(t, `{"base_path": "/base", "routes": {"/echo": {"wasm_file": "{echo}"}}}`)
+	h := stripBasePath("/base", s, s.writeError)
+
+	if name := decodeEcho(t, serve(h, "GET", "/base/echo")).Name; name != "echo" {
+		t.Errorf("/base/echo ran %q", name)
+	}
+	for _, target := range []string{"/echo", "/basement/echo"} {
+		w := serve(h, "GET", target, "Accept", "application/json")
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: got %d %q, want a JSON 404", target, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	tests := []struct {
+		name string
+		sh   SecurityHeaders
+		want map[string]string
+	}{
+		{"defaults", SecurityHeaders{}, map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "DENY",
+			"Referrer-Policy":         "strict-origin-when-cross-origin",
+			"Content-Security-Policy": defaultCSP,
+		}},
+		{"configured", SecurityHeaders{
+			FrameOptions:          "SAMEORIGIN",
+			ReferrerPolicy:        "no-referrer",
+			ContentSecurityPolicy: "default-src 'none'",
+		}, map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "SAMEORIGIN",
+			"Referrer-Policy":         "no-referrer",
+			"Content-Security-Policy": "default-src 'none'",
+		}},
+	}
+	for _, tt := range tests {
+		w := serve(tt.sh.Middleware(next), "GET", "/")
+		// Error responses are hardened too
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want next's 404", tt.name, w.Code)
+		}
+		for header, want := range tt.want {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, header, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxHeaderBlock bounds how much output a streaming route holds back while
+// waiting for its header block to be closed.
+const maxHeaderBlock = 64 << 10
+
+// streamWriter passes instrument output on to the client as it is written,
+// for routes with "stream": true. On routes that also set headers, output is
+// held back until the header block is complete or ruled out.
+type streamWriter struct {
+	w       http.ResponseWriter
+	route   Route
+	rc      *http.ResponseController
+	pending []byte
+	started bool
+	// redirect is set once a Location header was sent; the body is discarded
+	redirect bool
+	err      error
+}
+
+func newStreamWriter(w http.ResponseWriter, route Route) *streamWriter {
+	return &streamWriter{w: w, route: route, rc: http.NewResponseController(w)}
+}
+
+// Write forwards p to the client and flushes it.
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if !sw.started {
+		sw.pending = append(sw.pending, p...)
+		if sw.route.Headers && len(sw.pending) < maxHeaderBlock {
+			if _, _, ok, more := scanHeaderBlock(sw.pending); !ok && more {
+				return len(p), nil
+			}
+		}
+		sw.start()
+		return len(p), nil
+	}
+	if !sw.redirect {
+		sw.w.Write(p)
+		sw.rc.Flush()
+	}
+	return len(p), nil
+}
+
+// start sends the headers and whatever output has been held back.
+func (sw *streamWriter) start() {
+	sw.started = true
+	out, err := sw.route.splitOutput(sw.pending)
+	sw.pending, sw.err = nil, err
+	for key, values := range out.header {
+		sw.w.Header()[key] = values
+	}
+	for _, c := range out.cookies {
+		http.SetCookie(sw.w, c)
+	}
+	if out.header.Get("Location") != "" {
+		sw.redirect = true
+		sw.w.WriteHeader(http.StatusFound)
+		return
+	}
+	sw.w.Write(out.body)
+	sw.rc.Flush()
+}
+
+// Close sends any output still held back once the instrument has exited. It
+// returns the header block's errors, if any.
+func (sw *streamWriter) Close() error {
+	if !sw.started {
+		sw.start()
+	}
+	return sw.err
+}
+
+// streamInstrument runs the instrument with its output streamed to the client.
+func (s *Server) streamInstrument(w http.ResponseWriter, r *http.Request, route Route, stdin io.Reader, payload RequestPayload) {
+	// Headers must be in place before the first byte goes out
+	if route.sendsSeed() {
+		w.Header().Set("X-WASIO-Seed", strconv.FormatInt(payload.Seed, 10))
+	}
+	sw := newStreamWriter(w, route)
+	err := s.moduleCache.RunInstrument(r.Context(), route, stdin, sw)
+	switch {
+	case err != nil && r.Context().Err() != nil:
+		route.logf(logNormal, "[%s] Client canceled %s", payload.RequestID, r.URL.Path)
+	case err != nil && !sw.started:
+		w.Header().Del("X-WASIO-Seed")
+		route.logf(logNormal, "[%s] Error running %s: %v", payload.RequestID, route.WasmFile, err)
+		s.writeError(w, r, http.StatusInternalServerError, fmt.Sprintf("Error running module: %v", err))
+	case err != nil:
+		// Part of the response is already sent, so the error can only be logged
+		route.logf(logNormal, "[%s] Error running %s after streaming began: %v", payload.RequestID, route.WasmFile, err)
+	default:
+		if err := sw.Close(); err != nil {
+			route.logf(logNormal, "[%s] %s: %v", payload.RequestID, route.WasmFile, err)
+		}
+	}
+}
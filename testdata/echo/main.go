@@ -0,0 +1,63 @@
+// Command echo is the instrument the server tests run. Given the server's
+// JSON payload it replies with {"name": ..., "payload": ...}, unless its
+// params ask it to do something else first. Any other input, such as a raw
+// request body, is echoed back unchanged.
+//
+// The tests build it twice, setting name with -ldflags "-X main.name=...",
+// to get two modules that can be told apart.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+var name = "echo"
+
+func main() {
+	in, _ := io.ReadAll(os.Stdin)
+	var payload struct {
+		Params map[string]string `json:"params"`
+	}
+	if json.Unmarshal(in, &payload) != nil {
+		os.Stdout.Write(in)
+		return
+	}
+
+	params := payload.Params
+	if msg := params["stderr"]; msg != "" {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	if ms, err := strconv.Atoi(params["sleep"]); err == nil {
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+	}
+	if params["clock"] != "" {
+		fmt.Print(time.Now().Unix())
+		return
+	}
+	if code, err := strconv.Atoi(params["exit"]); err == nil {
+		os.Exit(code)
+	}
+	if file := params["read"]; file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+	// out is printed as-is, so tests control the exact output, header block included
+	if out, ok := params["out"]; ok {
+		fmt.Print(out)
+		return
+	}
+	json.NewEncoder(os.Stdout).Encode(struct {
+		Name    string          `json:"name"`
+		Payload json.RawMessage `json:"payload"`
+	}{name, in})
+}
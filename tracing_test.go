@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	s := newTestServer(t, `{"cache_ttl": 60, "routes": {"/calc/:op": {"wasm_file": "{echo}", "cache": true}}}`)
+	// A cache of its own, so the module is compiled within the traced request
+	mc, err := NewModuleCache("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mc.rt.Close(context.Background())
+	s.moduleCache = mc
+	h := withTracing(s)
+
+	decodeEcho(t, serve(h, "GET", "/calc/add"))
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	request, compile, run := spans["GET /calc/add"], spans["CompileModule"], spans["RunInstrument"]
+	if request == nil || compile == nil || run == nil {
+		t.Fatalf("spans = %v, want the request, CompileModule and RunInstrument", spans)
+	}
+	for _, child := range []sdktrace.ReadOnlySpan{compile, run} {
+		if child.Parent().SpanID() != request.SpanContext().SpanID() || child.SpanContext().TraceID() != request.SpanContext().TraceID() {
+			t.Errorf("%s is not a child of the request span", child.Name())
+		}
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range request.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs["wasio.route"].AsString(); got != "/calc/:op" {
+		t.Errorf("wasio.route = %q, want the route pattern", got)
+	}
+	if got := attrs["wasio.cache_hit"]; got.Type() != attribute.BOOL || got.AsBool() {
+		t.Errorf("wasio.cache_hit = %v, want false", got.Emit())
+	}
+	if got := attrs["http.response.status_code"].AsInt64(); got != 200 {
+		t.Errorf("status code attribute = %d", got)
+	}
+
+	// The second request is answered from the cache without running anything
+	before := len(recorder.Ended())
+	serve(h, "GET", "/calc/add")
+	ended := recorder.Ended()[before:]
+	if len(ended) != 1 {
+		t.Fatalf("cache hit recorded %d spans, want just the request", len(ended))
+	}
+	for _, kv := range ended[0].Attributes() {
+		if kv.Key == "wasio.cache_hit" && !kv.Value.AsBool() {
+			t.Error("cache hit not recorded")
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxUploadSize limits the total size of a multipart upload.
+const maxUploadSize = 32 << 20
+
+// isMultipart reports whether r carries a multipart/form-data body.
+func isMultipart(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// saveUploads parses a multipart form and writes each uploaded file into dir.
+// It returns the form's text fields plus, for every file field, the name the
+// file was saved under in dir.
+func saveUploads(w http.ResponseWriter, r *http.Request, dir string) (map[string]string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		return nil, err
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	params := map[string]string{}
+	for field, values := range r.MultipartForm.Value {
+		params[field] = values[0]
+	}
+	used := map[string]bool{}
+	for field, headers := range r.MultipartForm.File {
+		names := make([]string, 0, len(headers))
+		for _, fh := range headers {
+			name := uploadName(fh.Filename, used)
+			if err := saveUpload(fh, filepath.Join(dir, name)); err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		params[field] = strings.Join(names, ",")
+	}
+	return params, nil
+}
+
+// uploadName returns a safe, unique file name for a client-supplied one.
+func uploadName(filename string, used map[string]bool) string {
+	name := filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = "upload"
+	}
+	unique := name
+	ext := filepath.Ext(name)
+	for i := 1; used[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[unique] = true
+	return unique
+}
+
+// saveUpload copies an uploaded file to dst.
+func saveUpload(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
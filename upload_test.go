@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// postForm posts a multipart form with the given text fields and files
+// (field name, file name, content) to target.
+func postForm(t *testing.T, h http.Handler, target string, fields map[string]string, files ...[3]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for _, f := range files {
+		fw, err := mw.CreateFormFile(f[0], f[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f[2]))
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestUpload(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	s := newTestServer(t, `{"routes": {"/upload": {"wasm_file": "{echo}", "upload_mount": "/upload"}}}`)
+
+	// The instrument reads the uploaded file back from the mount
+	w := postForm(t, s, "/upload?read=/upload/notes.txt", nil, [3]string{"file", "../../notes.txt", "uploaded content"})
+	if w.Code != http.StatusOK || w.Body.String() != "uploaded content" {
+		t.Errorf("got %d %q, want the uploaded file", w.Code, w.Body)
+	}
+
+	w = postForm(t, s, "/upload", map[string]string{"note": "hi"},
+		[3]string{"file", "a.txt", "1"}, [3]string{"file", "a.txt", "2"})
+	params := decodeEcho(t, w).Payload.Params
+	if params["note"] != "hi" || params["file"] != "a.txt,a-1.txt" {
+		t.Errorf("params = %v, want note and both saved file names", params)
+	}
+
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("upload directories left behind: %v", entries)
+	}
+}
+
+func TestUploadTooLarge(t *testing.T) {
+	s := newTestServer(t, `{"routes": {"/upload": {"wasm_file": "{echo}", "upload_mount": "/upload"}}}`)
+	big := string(bytes.Repeat([]byte("x"), maxUploadSize+1))
+	if w := postForm(t, s, "/upload", nil, [3]string{"file", "big.bin", big}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", w.Code)
+	}
+}